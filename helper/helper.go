@@ -5,7 +5,9 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
+	"net/url"
 	"os"
+	"strings"
 	"sync"
 	"time"
 	"unsafe"
@@ -106,3 +108,18 @@ func Base64Decode(input string) (string, error) {
 	}
 	return ByteSlice2String(output), nil
 }
+
+// SafeRedirectPath returns next when it is a same-origin path, or an empty string otherwise
+func SafeRedirectPath(next string) string {
+	if next == "" ||
+		next[0] != '/' ||
+		strings.HasPrefix(next, "//") ||
+		strings.ContainsAny(next, "\\\r\n\t") {
+		return ""
+	}
+	location, err := url.Parse(next)
+	if err != nil || location.Scheme != "" || location.Host != "" || location.User != nil {
+		return ""
+	}
+	return next
+}
@@ -0,0 +1,35 @@
+package helper
+
+import (
+	"testing"
+)
+
+func TestSafeRedirectPath(t *testing.T) {
+	tests := []struct {
+		name string
+		next string
+		want string
+	}{
+		{"empty", "", ""},
+		{"same-origin path", "/account/me/about", "/account/me/about"},
+		{"same-origin path with a query", "/admin/users?page=2&sort=name", "/admin/users?page=2&sort=name"},
+		{"protocol-relative URL", "//evil.com", ""},
+		{"protocol-relative URL with extra slashes", "///evil.com", ""},
+		{"backslash read as a slash by browsers", "/\\evil.com", ""},
+		{"absolute URL", "https://evil.com", ""},
+		{"scheme without slashes", "javascript:alert(1)", ""},
+		{"relative path", "evil.com", ""},
+		{"encoded slashes stay a same-origin path", "/%2F%2Fevil.com", "/%2F%2Fevil.com"},
+		{"encoded slashes without a leading slash", "%2F%2Fevil.com", ""},
+		{"encoded backslash stays a same-origin path", "/%5Cevil.com", "/%5Cevil.com"},
+		{"header injection", "/account\r\nLocation: https://evil.com", ""},
+		{"tab before a slash", "/\t/evil.com", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SafeRedirectPath(tt.next); got != tt.want {
+				t.Errorf("SafeRedirectPath(%q) = %q, want %q", tt.next, got, tt.want)
+			}
+		})
+	}
+}
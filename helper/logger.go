@@ -17,6 +17,10 @@ const (
 	service = "bracha"
 )
 
+type (
+	requestIDKey struct{}
+)
+
 var (
 	logger     *zap.Logger
 	InitLogger = sync.OnceFunc(func() {
@@ -46,7 +50,18 @@ func GetLogger() *zap.Logger {
 	return logger
 }
 
-func logContext(_ context.Context, context, scope string) *zap.Logger {
+// WithRequestID returns a copy of ctx carrying the request's correlation ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the correlation ID placed in ctx by WithRequestID.
+func RequestID(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey{}).(string)
+	return requestID, ok && requestID != ""
+}
+
+func logContext(ctx context.Context, context, scope string) *zap.Logger {
 	defer func() {
 		_ = logger.Sync()
 	}()
@@ -58,6 +73,9 @@ func logContext(_ context.Context, context, scope string) *zap.Logger {
 	if scope != "" {
 		fields = append(fields, zap.String("scope", scope))
 	}
+	if requestID, ok := RequestID(ctx); ok {
+		fields = append(fields, zap.String("request_id", requestID))
+	}
 	return logger.With(fields...)
 }
 
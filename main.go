@@ -13,22 +13,37 @@
 
 //	@schemes	http https
 
-//	@securitydefinitions.apikey	apiKey
+//	@securitydefinitions.apikey	ApiKeyAuth
 //	@in							header
-//	@name						x-api-key
+//	@name						X-Api-Key
 //	@description				Bracha API call requires X-Api-Key request header
 
+//	@securitydefinitions.apikey	BearerAuth
+//	@in							header
+//	@name						Authorization
+//	@description				Sadia JWT as "Bearer <token>"
+
 // @Security	ApiKeyAuth
 package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"github.com/robfig/cron/v3"
 	"github.com/roysitumorang/bracha/config"
 	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/migrations"
+	apiKeyModel "github.com/roysitumorang/bracha/modules/apikey/model"
+	apiKeyQuery "github.com/roysitumorang/bracha/modules/apikey/query"
 	"github.com/roysitumorang/bracha/router"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
@@ -67,6 +82,11 @@ func main() {
 			g.Go(func() error {
 				return service.HTTPServerMain(ctx)
 			})
+			if service.SadiaVerifier != nil {
+				g.Go(func() error {
+					return service.SadiaVerifier.Run(ctx)
+				})
+			}
 			g.Go(func() error {
 				c := cron.New(cron.WithChain(
 					cron.Recover(cron.DefaultLogger),
@@ -80,10 +100,161 @@ func main() {
 			}
 		},
 	}
+	cmdMigrate := &cobra.Command{
+		Use:   "migrate",
+		Short: "run database migrations",
+		Run: func(_ *cobra.Command, _ []string) {
+			if err := godotenv.Load(".env"); err != nil {
+				helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrLoad")
+				return
+			}
+			db, err := router.NewDB(ctx)
+			if err != nil {
+				helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrNewDB")
+				return
+			}
+			defer db.Close()
+			if err = migrations.Run(ctx, db); err != nil {
+				helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrRun")
+			}
+		},
+	}
+	var (
+		apiKeyName, apiKeyCompanyID string
+		apiKeyPermissions           []string
+	)
+	cmdAPIKeyCreate := &cobra.Command{
+		Use:   "create",
+		Short: "create api key, printing it once",
+		Run: func(_ *cobra.Command, _ []string) {
+			if apiKeyName == "" || apiKeyCompanyID == "" {
+				fmt.Println("--name and --company are required")
+				return
+			}
+			for _, permission := range apiKeyPermissions {
+				if !slices.Contains(apiKeyModel.Permissions, permission) {
+					fmt.Printf("unknown permission %q, expected one of %s\n", permission, strings.Join(apiKeyModel.Permissions, ", "))
+					return
+				}
+			}
+			if err := godotenv.Load(".env"); err != nil {
+				helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrLoad")
+				return
+			}
+			db, err := router.NewDB(ctx)
+			if err != nil {
+				helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrNewDB")
+				return
+			}
+			defer db.Close()
+			key, prefix := apiKeyModel.GenerateKey()
+			apiKey := apiKeyModel.APIKey{
+				ID:          uuid.NewString(),
+				Name:        apiKeyName,
+				Prefix:      prefix,
+				KeyHash:     apiKeyModel.HashKey(key),
+				CompanyID:   apiKeyCompanyID,
+				Permissions: apiKeyPermissions,
+			}
+			if err = apiKeyQuery.New(db, db).CreateAPIKey(ctx, &apiKey); err != nil {
+				helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrCreateAPIKey")
+				return
+			}
+			fmt.Printf("ID: %s\nKey: %s\nStore the key now, it cannot be shown again.\n", apiKey.ID, key)
+		},
+	}
+	cmdAPIKeyCreate.Flags().StringVar(&apiKeyName, "name", "", "name of the client using the key")
+	cmdAPIKeyCreate.Flags().StringVar(&apiKeyCompanyID, "company", "", "company the key is scoped to")
+	cmdAPIKeyCreate.Flags().StringSliceVar(&apiKeyPermissions, "permission", nil, "permission granted to the key, repeatable")
+	cmdAPIKeyList := &cobra.Command{
+		Use:   "list",
+		Short: "list api keys",
+		Run: func(_ *cobra.Command, _ []string) {
+			if err := godotenv.Load(".env"); err != nil {
+				helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrLoad")
+				return
+			}
+			db, err := router.NewDB(ctx)
+			if err != nil {
+				helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrNewDB")
+				return
+			}
+			defer db.Close()
+			apiKeys, err := apiKeyQuery.New(db, db).FindAPIKeys(ctx, apiKeyCompanyID)
+			if err != nil {
+				helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrFindAPIKeys")
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tPREFIX\tCOMPANY\tPERMISSIONS\tCREATED\tLAST USED\tREVOKED")
+			for _, apiKey := range apiKeys {
+				lastUsedAt, revokedAt := "-", "-"
+				if apiKey.LastUsedAt != nil {
+					lastUsedAt = apiKey.LastUsedAt.Format(time.RFC3339)
+				}
+				if apiKey.RevokedAt != nil {
+					revokedAt = apiKey.RevokedAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(
+					w,
+					"%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					apiKey.ID,
+					apiKey.Name,
+					apiKey.Prefix,
+					apiKey.CompanyID,
+					strings.Join(apiKey.Permissions, ","),
+					apiKey.CreatedAt.Format(time.RFC3339),
+					lastUsedAt,
+					revokedAt,
+				)
+			}
+			if err = w.Flush(); err != nil {
+				helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrFlush")
+			}
+		},
+	}
+	cmdAPIKeyList.Flags().StringVar(&apiKeyCompanyID, "company", "", "only list keys of this company")
+	cmdAPIKeyRevoke := &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "revoke api key",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			if err := godotenv.Load(".env"); err != nil {
+				helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrLoad")
+				return
+			}
+			db, err := router.NewDB(ctx)
+			if err != nil {
+				helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrNewDB")
+				return
+			}
+			defer db.Close()
+			if err = apiKeyQuery.New(db, db).RevokeAPIKey(ctx, args[0]); err != nil {
+				if errors.Is(err, apiKeyModel.ErrNotFound) {
+					fmt.Printf("no active api key with id %s\n", args[0])
+					return
+				}
+				helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrRevokeAPIKey")
+				return
+			}
+			fmt.Printf("Revoked %s\n", args[0])
+		},
+	}
+	cmdAPIKey := &cobra.Command{
+		Use:   "apikey",
+		Short: "manage api keys",
+	}
+	cmdAPIKey.AddCommand(
+		cmdAPIKeyCreate,
+		cmdAPIKeyList,
+		cmdAPIKeyRevoke,
+	)
 	rootCmd := &cobra.Command{Use: config.AppName}
 	rootCmd.AddCommand(
 		cmdVersion,
 		cmdRun,
+		cmdMigrate,
+		cmdAPIKey,
 	)
 	rootCmd.SuggestionsMinimumDistance = 1
 	if err := rootCmd.Execute(); err != nil {
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/roysitumorang/bracha/helper"
+	apiKeyModel "github.com/roysitumorang/bracha/modules/apikey/model"
+	apiKeyQuery "github.com/roysitumorang/bracha/modules/apikey/query"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderAPIKey = "X-Api-Key"
+)
+
+// APIKey authenticates machine clients by the X-Api-Key header, requiring the key to
+// hold every one of permissions, and scopes the request to the key's company.
+func APIKey(apiKeyQuery apiKeyQuery.APIKeyQuery, permissions ...string) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		ctxt := "Middleware-APIKey"
+		ctx := c.UserContext()
+		key := c.Get(HeaderAPIKey)
+		if key == "" {
+			return helper.NewResponse(fiber.StatusUnauthorized).SetMessage("Unauthorized").WriteResponse(c)
+		}
+		apiKey, err := apiKeyQuery.FindActiveAPIKeyByHash(ctx, apiKeyModel.HashKey(key))
+		if errors.Is(err, apiKeyModel.ErrNotFound) {
+			return helper.NewResponse(fiber.StatusUnauthorized).SetMessage("Unauthorized").WriteResponse(c)
+		}
+		if err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrFindActiveAPIKeyByHash")
+			return helper.NewResponse(fiber.StatusInternalServerError).SetMessage(err.Error()).WriteResponse(c)
+		}
+		if !apiKey.HasPermissions(permissions...) {
+			return helper.NewResponse(fiber.StatusForbidden).SetMessage("Forbidden").WriteResponse(c)
+		}
+		if err = apiKeyQuery.TouchAPIKey(ctx, apiKey.ID); err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrTouchAPIKey")
+		}
+		company := Company{ID: apiKey.CompanyID}
+		c.Locals("company", company)
+		ctx = context.WithValue(ctx, apiKeyKey, apiKey)
+		ctx = context.WithValue(ctx, companyKey, company)
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
+
+// RequirePermission rejects requests whose API key, placed by APIKey, lacks any of permissions.
+func RequirePermission(permissions ...string) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		if apiKey, ok := CurrentAPIKey(c.UserContext()); !ok || !apiKey.HasPermissions(permissions...) {
+			return helper.NewResponse(fiber.StatusForbidden).SetMessage("Forbidden").WriteResponse(c)
+		}
+		return c.Next()
+	}
+}
+
+// CurrentAPIKey returns the API key placed in ctx by APIKey.
+func CurrentAPIKey(ctx context.Context) (*apiKeyModel.APIKey, bool) {
+	apiKey, ok := ctx.Value(apiKeyKey).(*apiKeyModel.APIKey)
+	return apiKey, ok
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/models"
+	"github.com/roysitumorang/bracha/services/identity"
+	"go.uber.org/zap"
+)
+
+// BearerAuth authenticates API callers by the JWT in the Authorization header, verified
+// locally before the identity provider is asked for the user, and exposes the user and JWT
+// the same way RequireLogin does. Behind APIKey the user must belong to the key's company.
+func BearerAuth(identityProvider identity.IdentityProvider) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		ctxt := "Middleware-BearerAuth"
+		ctx := c.UserContext()
+		jwt, ok := BearerToken(c)
+		if !ok {
+			return helper.NewResponse(fiber.StatusUnauthorized).SetMessage("Unauthorized").WriteResponse(c)
+		}
+		if _, err := identityProvider.Verify(ctx, jwt); err != nil {
+			helper.Log(ctx, zap.InfoLevel, err.Error(), ctxt, "ErrVerify")
+			if errors.Is(err, identity.ErrUnavailable) {
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(identity.RetryAfter.Seconds())))
+				return helper.NewResponse(fiber.StatusServiceUnavailable).SetMessage(identity.UserMessage(err)).WriteResponse(c)
+			}
+			return helper.NewResponse(fiber.StatusUnauthorized).SetMessage("Unauthorized").WriteResponse(c)
+		}
+		response, err := identityProvider.Me(ctx, jwt)
+		if err == nil {
+			err = response.Expect(fiber.StatusOK)
+		}
+		if err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrMe")
+			statusCode := identity.StatusCode(err)
+			if statusCode == fiber.StatusUnauthorized {
+				return helper.NewResponse(fiber.StatusUnauthorized).SetMessage("Unauthorized").WriteResponse(c)
+			}
+			return helper.NewResponse(statusCode).SetMessage(identity.UserMessage(err)).WriteResponse(c)
+		}
+		if company, ok := CurrentCompany(ctx); ok && !company.Allows(response.Data.CompanyID) {
+			return helper.NewResponse(fiber.StatusForbidden).SetMessage("Forbidden").WriteResponse(c)
+		}
+		c.Locals(models.CurrentUser, response.Data)
+		c.Locals(models.CurrentJwt, jwt)
+		ctx = context.WithValue(ctx, currentUserKey, response.Data)
+		ctx = context.WithValue(ctx, currentJwtKey, jwt)
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
+
+// BearerToken extracts the token from an "Authorization: Bearer <token>" header.
+func BearerToken(c *fiber.Ctx) (string, bool) {
+	scheme, token, ok := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
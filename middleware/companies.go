@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/models"
+	"github.com/roysitumorang/bracha/services/identity"
+	"go.uber.org/zap"
+)
+
+const (
+	userCompaniesExpiry = 5 * time.Minute
+)
+
+// LoadCompanies exposes the companies the current user belongs to as the companies view
+// variable, for the company switcher in the header. Memberships are cached in the session
+// storage for a few minutes. It must run after RequireLogin.
+func LoadCompanies(sessionStore *session.Store, identityProvider identity.IdentityProvider) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		ctxt := "Middleware-LoadCompanies"
+		ctx := c.UserContext()
+		currentUser, ok := CurrentUser(ctx)
+		if !ok {
+			return c.Next()
+		}
+		companies, err := UserCompanies(c, sessionStore, identityProvider)
+		if err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrUserCompanies")
+			return c.Next()
+		}
+		c.Locals("companies", companies)
+		c.Locals("currentCompanyID", SelectedCompanyID(Session(c), currentUser))
+		c.Locals("currentPath", c.OriginalURL())
+		return c.Next()
+	}
+}
+
+// UserCompanies returns the companies the current user belongs to, from cache when possible.
+func UserCompanies(c *fiber.Ctx, sessionStore *session.Store, identityProvider identity.IdentityProvider) (companies []identity.Company, err error) {
+	ctxt := "Middleware-UserCompanies"
+	ctx := c.UserContext()
+	currentUser, _ := CurrentUser(ctx)
+	key := models.UserCompaniesPrefix + currentUser.ID
+	cache, err := sessionStore.Storage.Get(key)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGet")
+	}
+	if len(cache) > 0 && json.Unmarshal(cache, &companies) == nil {
+		return companies, nil
+	}
+	jwt, _ := CurrentJwt(ctx)
+	response, err := identityProvider.ListCompanies(ctx, jwt)
+	if err == nil {
+		err = response.Expect(fiber.StatusOK)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrListCompanies")
+		return nil, err
+	}
+	companies = response.Data
+	if cache, err = json.Marshal(companies); err == nil {
+		if err = sessionStore.Storage.Set(key, cache, userCompaniesExpiry); err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSet")
+		}
+	}
+	return companies, nil
+}
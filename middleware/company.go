@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/models"
+	"github.com/roysitumorang/bracha/services/identity"
+)
+
+const (
+	TenantResolverSession   = "session"
+	TenantResolverSubdomain = "subdomain"
+)
+
+type (
+	// Company is the tenant a request is scoped to.
+	Company struct {
+		ID string
+		// CrossTenant callers may act on any company.
+		CrossTenant bool
+	}
+)
+
+// ResolveCompany scopes the request to the caller's company, taken from the admin placed by
+// RequireAdminLogin or else the user placed by RequireLogin. With resolver TenantResolverSubdomain
+// the company may also be picked by the single label in front of baseDomain, as in
+// acme.bracha.example for base domain bracha.example, which only cross-tenant callers may point
+// at a company other than their own.
+func ResolveCompany(resolver, baseDomain string) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		principal, ok := CurrentAdmin(ctx)
+		companyID := principal.CompanyID
+		if !ok {
+			if principal, ok = CurrentUser(ctx); !ok {
+				return c.Next()
+			}
+			companyID = SelectedCompanyID(Session(c), principal)
+		}
+		company := Company{
+			ID:          companyID,
+			CrossTenant: models.HasAnyRole(principal.AccountType, principal.UserLevel, models.RoleSuperAdmin),
+		}
+		if resolver == TenantResolverSubdomain {
+			if subdomain, ok := tenantSubdomain(c.Hostname(), baseDomain); ok && subdomain != company.ID {
+				if !company.CrossTenant {
+					if WantsJSON(c) {
+						return helper.NewResponse(fiber.StatusForbidden).SetMessage("Forbidden").WriteResponse(c)
+					}
+					return c.Status(fiber.StatusForbidden).Render("errors/403", fiber.Map{})
+				}
+				company.ID = subdomain
+			}
+		}
+		c.Locals("company", company)
+		c.SetUserContext(context.WithValue(ctx, companyKey, company))
+		return c.Next()
+	}
+}
+
+// SelectedCompanyID returns the company user switched to in session, or else their home company.
+func SelectedCompanyID(session *session.Session, user identity.User) string {
+	if session != nil {
+		if companyID, ok := session.Get(models.CurrentCompanyID).(string); ok && companyID != "" {
+			return companyID
+		}
+	}
+	return user.CompanyID
+}
+
+// tenantSubdomain returns the single label host has below baseDomain, if any. The www label
+// names the base domain itself rather than a company.
+func tenantSubdomain(host, baseDomain string) (string, bool) {
+	subdomain, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(baseDomain))
+	if !ok || subdomain == "" || subdomain == "www" || strings.Contains(subdomain, ".") {
+		return "", false
+	}
+	return subdomain, true
+}
+
+// CurrentCompany returns the company placed in ctx by ResolveCompany.
+func CurrentCompany(ctx context.Context) (Company, bool) {
+	company, ok := ctx.Value(companyKey).(Company)
+	return company, ok
+}
+
+// Allows reports whether the company scope may see records belonging to companyID.
+func (q Company) Allows(companyID string) bool {
+	return q.CrossTenant || q.ID == companyID
+}
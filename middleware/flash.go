@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/roysitumorang/bracha/models"
+)
+
+// PopFlash returns the flash message carried across a redirect and removes it from the session.
+func PopFlash(session *session.Session) (string, error) {
+	message, ok := session.Get(models.FlashMessage).(string)
+	if !ok {
+		return "", nil
+	}
+	session.Delete(models.FlashMessage)
+	return message, session.Save()
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/models"
+	"github.com/roysitumorang/bracha/services/identity"
+	"go.uber.org/zap"
+)
+
+// Impersonation exposes impersonatedUser and impersonatingAdmin to the views
+// while an admin is signed in as another user, so the header can show a banner.
+// The impersonated user lives in the user session, the admin in the admin session.
+func Impersonation(sessionStore, adminSessionStore *session.Store) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		ctxt := "Middleware-Impersonation"
+		// both sessions are only read when stored, so a visitor lacking one keeps the other intact
+		session, err := StoredSession(c, sessionStore, SessionCookie)
+		if err != nil {
+			helper.Log(c.UserContext(), zap.ErrorLevel, err.Error(), ctxt, "ErrStoredSession")
+			return c.SendString(err.Error())
+		}
+		if session == nil {
+			return c.Next()
+		}
+		if isImpersonating, ok := session.Get(models.IsImpersonating).(bool); ok && isImpersonating {
+			if impersonatedUser, ok := session.Get(models.CurrentUser).(identity.User); ok {
+				c.Locals("impersonatedUser", impersonatedUser)
+			}
+			adminSession, err := StoredSession(c, adminSessionStore, AdminSessionCookie)
+			if err != nil {
+				helper.Log(c.UserContext(), zap.ErrorLevel, err.Error(), ctxt, "ErrStoredSession")
+				return c.SendString(err.Error())
+			}
+			if adminSession == nil {
+				return c.Next()
+			}
+			if impersonatingAdmin, ok := adminSession.Get(models.CurrentAdmin).(identity.User); ok {
+				c.Locals("impersonatingAdmin", impersonatingAdmin)
+			}
+		}
+		return c.Next()
+	}
+}
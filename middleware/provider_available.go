@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/services/identity"
+)
+
+// ProviderAvailable short-circuits requests with a friendly 503 while the identity provider
+// is unavailable, such as when the circuit breaker guarding Sadia is open, instead of
+// letting every handler fail on its own.
+func ProviderAvailable(identityProvider identity.IdentityProvider) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		// logging out only clears the local session, so it keeps working during an outage
+		if identityProvider.Available() || strings.HasSuffix(c.Path(), "/logout") {
+			return c.Next()
+		}
+		return ServiceUnavailable(c)
+	}
+}
+
+// ServiceUnavailable answers with a 503 asking the caller to retry after identity.RetryAfter:
+// a JSON envelope for API callers, the errors/503 page otherwise.
+func ServiceUnavailable(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(identity.RetryAfter.Seconds())))
+	if WantsJSON(c) {
+		return helper.NewResponse(fiber.StatusServiceUnavailable).SetMessage(identity.UserMessage(identity.ErrUnavailable)).WriteResponse(c)
+	}
+	return c.Status(fiber.StatusServiceUnavailable).Render("errors/503", fiber.Map{
+		"retryURL": c.OriginalURL(),
+	})
+}
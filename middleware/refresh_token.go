@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/models"
+	"github.com/roysitumorang/bracha/services/identity"
+	"go.uber.org/zap"
+)
+
+// RefreshToken renews the session's JWT once it is within window of its expiry, logging
+// the user out cleanly when the identity provider rejects the refresh. While the provider
+// can't be reached or rate limits the refresh, a JWT that hasn't expired yet is kept until
+// the next request.
+func RefreshToken(sessionStore *session.Store, identityProvider identity.IdentityProvider, window time.Duration) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		ctxt := "Middleware-RefreshToken"
+		ctx := c.UserContext()
+		session, err := sessionStore.Get(c)
+		if err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGet")
+			return c.SendString(err.Error())
+		}
+		if isAuthenticated, ok := session.Get(models.IsAuthenticated).(bool); !ok || !isAuthenticated {
+			return c.Next()
+		}
+		jwt, _ := session.Get(models.CurrentJwt).(string)
+		expiredAt, ok := session.Get(models.CurrentJwtExpiredAt).(time.Time)
+		if ok && time.Until(expiredAt) > window {
+			return c.Next()
+		}
+		response, err := identityProvider.Refresh(ctx, jwt)
+		if err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrRefresh")
+			if (errors.Is(err, identity.ErrUnavailable) || errors.Is(err, identity.ErrRateLimited)) && ok && time.Now().Before(expiredAt) {
+				return c.Next()
+			}
+			if err = session.Destroy(); err != nil {
+				helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrDestroy")
+				return c.SendString(err.Error())
+			}
+			return c.Redirect("/account/login?next=" + url.QueryEscape(c.OriginalURL()))
+		}
+		session.Set(models.CurrentUser, response.Data.User)
+		session.Set(models.CurrentJwt, response.Data.IDToken)
+		session.Set(models.CurrentJwtExpiredAt, response.Data.ExpiredAt)
+		session.SetExpiry(time.Until(response.Data.ExpiredAt))
+		if providerSessionID := response.Data.User.CurrentSessionID; providerSessionID != nil {
+			if err = sessionStore.Storage.Set(models.ProviderSessionPrefix+*providerSessionID, helper.String2ByteSlice(session.ID()), time.Until(response.Data.ExpiredAt)); err != nil {
+				helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSet")
+			}
+		}
+		if err = session.Save(); err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
+			return c.SendString(err.Error())
+		}
+		return c.Next()
+	}
+}
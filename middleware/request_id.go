@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/roysitumorang/bracha/helper"
+)
+
+// RequestID carries the ID assigned by Fiber's requestid middleware into c.UserContext(),
+// so log lines and outbound Sadia calls share the inbound request's correlation ID.
+func RequestID() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		if requestID, ok := c.Locals(requestid.ConfigDefault.ContextKey).(string); ok {
+			c.SetUserContext(helper.WithRequestID(c.UserContext(), requestID))
+		}
+		return c.Next()
+	}
+}
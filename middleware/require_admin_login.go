@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/models"
+	"github.com/roysitumorang/bracha/services/identity"
+	"go.uber.org/zap"
+)
+
+// RequireAdminLogin is RequireLogin for the admin area. The admin lives in a session store
+// with its own cookie, so signing in or out on either side doesn't disturb the other.
+func RequireAdminLogin(adminSessionStore *session.Store) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		ctxt := "Middleware-RequireAdminLogin"
+		ctx := c.UserContext()
+		session, err := adminSessionStore.Get(c)
+		if err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGet")
+			return c.SendString(err.Error())
+		}
+		isAdminAuthenticated, _ := session.Get(models.IsAdminAuthenticated).(bool)
+		currentAdmin, ok := session.Get(models.CurrentAdmin).(identity.User)
+		expiredAt, _ := session.Get(models.CurrentAdminJwtExpiredAt).(time.Time)
+		if !isAdminAuthenticated || !ok || time.Now().After(expiredAt) {
+			if WantsJSON(c) {
+				return helper.NewResponse(fiber.StatusUnauthorized).SetMessage("Unauthorized").WriteResponse(c)
+			}
+			return c.Redirect("/admin/login?next=" + url.QueryEscape(c.OriginalURL()))
+		}
+		jwt, _ := session.Get(models.CurrentAdminJwt).(string)
+		c.Locals(localsSession, session)
+		c.Locals(models.CurrentAdmin, currentAdmin)
+		c.Locals(models.CurrentAdminJwt, jwt)
+		ctx = context.WithValue(ctx, currentAdminKey, currentAdmin)
+		ctx = context.WithValue(ctx, currentAdminJwtKey, jwt)
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
+
+// CurrentAdmin returns the admin placed in ctx by RequireAdminLogin.
+func CurrentAdmin(ctx context.Context) (identity.User, bool) {
+	currentAdmin, ok := ctx.Value(currentAdminKey).(identity.User)
+	return currentAdmin, ok
+}
+
+// CurrentAdminJwt returns the Sadia admin JWT placed in ctx by RequireAdminLogin.
+func CurrentAdminJwt(ctx context.Context) (string, bool) {
+	jwt, ok := ctx.Value(currentAdminJwtKey).(string)
+	return jwt, ok
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/models"
+	"github.com/roysitumorang/bracha/services/identity"
+	"go.uber.org/zap"
+)
+
+type (
+	contextKey uint8
+)
+
+const (
+	currentUserKey contextKey = iota
+	currentJwtKey
+	currentAdminKey
+	currentAdminJwtKey
+	companyKey
+	apiKeyKey
+)
+
+const (
+	localsSession = "session"
+)
+
+// RequireLogin loads the session once and exposes the current user and JWT
+// through Session, CurrentUser and CurrentJwt. A session whose JWT fails
+// verification is destroyed, unless the provider's signing keys can't be
+// fetched, which answers 503 and keeps the session. Anonymous HTML callers are redirected to the
+// login page, API callers get a 401 envelope.
+func RequireLogin(sessionStore *session.Store, tokenVerifier identity.TokenVerifier) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		ctxt := "Middleware-RequireLogin"
+		ctx := c.UserContext()
+		session, err := sessionStore.Get(c)
+		if err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGet")
+			return c.SendString(err.Error())
+		}
+		isAuthenticated, _ := session.Get(models.IsAuthenticated).(bool)
+		currentUser, ok := session.Get(models.CurrentUser).(identity.User)
+		jwt, _ := session.Get(models.CurrentJwt).(string)
+		if isAuthenticated && ok {
+			if _, err = tokenVerifier.Verify(ctx, jwt); err != nil {
+				helper.Log(ctx, zap.InfoLevel, err.Error(), ctxt, "ErrVerify")
+				if errors.Is(err, identity.ErrUnavailable) {
+					return ServiceUnavailable(c)
+				}
+				if err = session.Destroy(); err != nil {
+					helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrDestroy")
+					return c.SendString(err.Error())
+				}
+				ok = false
+			}
+		}
+		if !isAuthenticated || !ok {
+			if WantsJSON(c) {
+				return helper.NewResponse(fiber.StatusUnauthorized).SetMessage("Unauthorized").WriteResponse(c)
+			}
+			return c.Redirect("/account/login?next=" + url.QueryEscape(c.OriginalURL()))
+		}
+		c.Locals(localsSession, session)
+		c.Locals(models.CurrentUser, currentUser)
+		c.Locals(models.CurrentJwt, jwt)
+		ctx = context.WithValue(ctx, currentUserKey, currentUser)
+		ctx = context.WithValue(ctx, currentJwtKey, jwt)
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
+
+// WantsJSON reports whether the caller prefers a JSON response over HTML.
+func WantsJSON(c *fiber.Ctx) bool {
+	return c.Accepts(fiber.MIMETextHTML, fiber.MIMEApplicationJSON) == fiber.MIMEApplicationJSON
+}
+
+// Session returns the session loaded by RequireLogin.
+func Session(c *fiber.Ctx) *session.Session {
+	session, _ := c.Locals(localsSession).(*session.Session)
+	return session
+}
+
+// CurrentUser returns the user placed in ctx by RequireLogin.
+func CurrentUser(ctx context.Context) (identity.User, bool) {
+	currentUser, ok := ctx.Value(currentUserKey).(identity.User)
+	return currentUser, ok
+}
+
+// CurrentJwt returns the JWT placed in ctx by RequireLogin.
+func CurrentJwt(ctx context.Context) (string, bool) {
+	jwt, ok := ctx.Value(currentJwtKey).(string)
+	return jwt, ok
+}
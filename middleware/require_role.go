@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/models"
+	"github.com/roysitumorang/bracha/services/identity"
+)
+
+// RequireRole only lets through callers holding at least one of roles.
+// It must run after RequireLogin, which places the current user in the request context.
+func RequireRole(roles ...models.Role) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		currentUser, ok := CurrentUser(c.UserContext())
+		return requireRole(c, currentUser, ok, "/account/login", roles...)
+	}
+}
+
+// RequireAdminRole is RequireRole for the admin area, checking the admin placed by RequireAdminLogin.
+func RequireAdminRole(roles ...models.Role) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		currentAdmin, ok := CurrentAdmin(c.UserContext())
+		return requireRole(c, currentAdmin, ok, "/admin/login", roles...)
+	}
+}
+
+func requireRole(c *fiber.Ctx, principal identity.User, ok bool, loginPath string, roles ...models.Role) error {
+	if !ok {
+		if WantsJSON(c) {
+			return helper.NewResponse(fiber.StatusUnauthorized).SetMessage("Unauthorized").WriteResponse(c)
+		}
+		return c.Redirect(loginPath)
+	}
+	if !models.HasAnyRole(principal.AccountType, principal.UserLevel, roles...) {
+		if WantsJSON(c) {
+			return helper.NewResponse(fiber.StatusForbidden).SetMessage("Forbidden").WriteResponse(c)
+		}
+		return c.Status(fiber.StatusForbidden).Render("errors/403", fiber.Map{})
+	}
+	return c.Next()
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/template/jet/v2"
+	"github.com/roysitumorang/bracha/models"
+	"github.com/roysitumorang/bracha/services/identity"
+)
+
+func TestRequireRole(t *testing.T) {
+	customer := identity.User{AccountType: models.AccountTypeCustomer}
+	staff := identity.User{AccountType: models.AccountTypeStaff}
+	tests := []struct {
+		name       string
+		user       *identity.User
+		accept     string
+		statusCode int
+		body       string
+		location   string
+	}{
+		{"staff passes", &staff, fiber.MIMETextHTML, fiber.StatusOK, "staff only", ""},
+		{"customer gets the 403 page", &customer, fiber.MIMETextHTML, fiber.StatusForbidden, "<h1>Forbidden</h1>", ""},
+		{"customer gets a 403 envelope", &customer, fiber.MIMEApplicationJSON, fiber.StatusForbidden, `"status_code":403`, ""},
+		{"anonymous is sent to login", nil, fiber.MIMETextHTML, fiber.StatusFound, "", "/account/login"},
+		{"anonymous gets a 401 envelope", nil, fiber.MIMEApplicationJSON, fiber.StatusUnauthorized, `"status_code":401`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{
+				Views: jet.New("../views", ".jet"),
+			})
+			app.Use(func(c *fiber.Ctx) error {
+				if tt.user != nil {
+					c.SetUserContext(context.WithValue(c.UserContext(), currentUserKey, *tt.user))
+				}
+				return c.Next()
+			})
+			app.Group("/staff", RequireRole(models.RoleStaff)).Get("", func(c *fiber.Ctx) error {
+				return c.SendString("staff only")
+			})
+			request := httptest.NewRequest(fiber.MethodGet, "/staff", nil)
+			request.Header.Set(fiber.HeaderAccept, tt.accept)
+			response, err := app.Test(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+			if response.StatusCode != tt.statusCode {
+				t.Fatalf("status code = %d, want %d", response.StatusCode, tt.statusCode)
+			}
+			body, err := io.ReadAll(response.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(body), tt.body) {
+				t.Errorf("body = %q, want it to contain %q", body, tt.body)
+			}
+			if location := response.Header.Get(fiber.HeaderLocation); location != tt.location {
+				t.Errorf("location = %q, want %q", location, tt.location)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+)
+
+const (
+	SessionCookie      = "session_id"
+	AdminSessionCookie = "admin_session_id"
+)
+
+// StoredSession returns the session the request's cookie names when store still holds it,
+// or nil. Unlike Store.Get it never starts a session: fiber parks a new session's ID in a
+// Locals slot every store shares, so the next store read in the request would pick it up.
+func StoredSession(c *fiber.Ctx, store *session.Store, cookie string) (*session.Session, error) {
+	id := c.Cookies(cookie)
+	if id == "" {
+		return nil, nil
+	}
+	data, err := store.Storage.Get(id)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return store.Get(c)
+}
@@ -0,0 +1,16 @@
+CREATE TABLE audit_logs (
+	id uuid NOT NULL PRIMARY KEY,
+	actor_id character varying NOT NULL,
+	actor_name character varying NOT NULL,
+	action character varying NOT NULL,
+	target_id character varying NOT NULL,
+	target_name character varying NOT NULL,
+	ip character varying NOT NULL,
+	succeeded boolean NOT NULL,
+	message text NOT NULL,
+	created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX audit_logs_actor_id_created_at_idx ON audit_logs (actor_id, created_at DESC);
+
+CREATE INDEX audit_logs_target_id_created_at_idx ON audit_logs (target_id, created_at DESC);
@@ -0,0 +1,13 @@
+CREATE TABLE api_keys (
+	id uuid NOT NULL PRIMARY KEY,
+	name character varying NOT NULL,
+	prefix character varying NOT NULL,
+	key_hash character varying NOT NULL UNIQUE,
+	company_id character varying NOT NULL,
+	permissions character varying[] NOT NULL DEFAULT '{}',
+	created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	last_used_at timestamp with time zone,
+	revoked_at timestamp with time zone
+);
+
+CREATE INDEX api_keys_company_id_idx ON api_keys (company_id);
@@ -0,0 +1,31 @@
+CREATE TABLE users (
+	id uuid NOT NULL PRIMARY KEY,
+	company_id character varying NOT NULL,
+	account_type smallint NOT NULL DEFAULT 0,
+	user_level smallint NOT NULL DEFAULT 0,
+	name character varying NOT NULL,
+	username character varying NOT NULL UNIQUE,
+	email character varying UNIQUE,
+	phone character varying UNIQUE,
+	password_hash character varying NOT NULL,
+	login_count integer NOT NULL DEFAULT 0,
+	current_login_at timestamp with time zone,
+	last_login_at timestamp with time zone,
+	login_failed_attempts integer NOT NULL DEFAULT 0,
+	login_locked_at timestamp with time zone,
+	last_password_change timestamp with time zone,
+	created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	deactivated_at timestamp with time zone
+);
+
+CREATE TABLE user_sessions (
+	id uuid NOT NULL PRIMARY KEY,
+	user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	last_seen_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expired_at timestamp with time zone NOT NULL,
+	revoked_at timestamp with time zone
+);
+
+CREATE INDEX user_sessions_user_id_idx ON user_sessions (user_id);
@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/roysitumorang/bracha/helper"
+	"go.uber.org/zap"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Run applies every embedded *.sql file not yet recorded in schema_migrations, in file name order.
+func Run(ctx context.Context, db *pgxpool.Pool) error {
+	ctxt := "Migrations-Run"
+	if _, err := db.Exec(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version character varying NOT NULL PRIMARY KEY,
+			applied_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+	); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrExec")
+		return err
+	}
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrGlob")
+		return err
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		version := strings.TrimSuffix(name, ".sql")
+		var exists bool
+		if err = db.QueryRow(
+			ctx,
+			`SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)`,
+			version,
+		).Scan(&exists); err != nil {
+			helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrScan")
+			return err
+		}
+		if exists {
+			continue
+		}
+		content, err := files.ReadFile(name)
+		if err != nil {
+			helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrReadFile")
+			return err
+		}
+		if err = pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, string(content)); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version)
+			return err
+		}); err != nil {
+			helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrBeginFunc")
+			return err
+		}
+		helper.Log(ctx, zap.InfoLevel, "migrated "+version, ctxt, "")
+	}
+	return nil
+}
@@ -1,8 +1,24 @@
 package models
 
 const (
-	IsAuthenticated = "is_authenticated"
-	CurrentAdmin    = "current_admin"
-	CurrentUser     = "current_user"
-	CurrentJwt      = "current_jwt"
+	IsAuthenticated          = "is_authenticated"
+	IsAdminAuthenticated     = "is_admin_authenticated"
+	CurrentAdmin             = "current_admin"
+	CurrentAdminJwt          = "current_admin_jwt"
+	CurrentAdminJwtExpiredAt = "current_admin_jwt_expired_at"
+	IsImpersonating          = "is_impersonating"
+	CurrentUser              = "current_user"
+	CurrentJwt               = "current_jwt"
+	CurrentJwtExpiredAt      = "current_jwt_expired_at"
+	CurrentCompanyID         = "current_company_id"
+	FlashMessage             = "flash_message"
+)
+
+const (
+	// ProviderSessionPrefix prefixes the storage key mapping an identity provider session ID to the local session ID.
+	ProviderSessionPrefix = "provider_session:"
+	// UserCompaniesPrefix prefixes the storage key caching the companies a user belongs to.
+	UserCompaniesPrefix = "user_companies:"
+	// PasswordResetLoginPrefix prefixes the storage key throttling password reset requests per login.
+	PasswordResetLoginPrefix = "password_reset_login:"
 )
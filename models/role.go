@@ -0,0 +1,51 @@
+package models
+
+type (
+	Role string
+)
+
+const (
+	AccountTypeCustomer uint8 = iota
+	AccountTypeStaff
+	AccountTypeAdmin
+)
+
+const (
+	// UserLevelSuperAdmin is the minimum Sadia user level granting RoleSuperAdmin to admin accounts.
+	UserLevelSuperAdmin uint8 = 9
+)
+
+const (
+	RoleUser  Role = "user"
+	RoleStaff Role = "staff"
+	RoleAdmin Role = "admin"
+	// RoleSuperAdmin is not bound to the caller's own company.
+	RoleSuperAdmin Role = "super_admin"
+)
+
+// Roles maps a Sadia account type and user level to the named roles it holds.
+func Roles(accountType, userLevel uint8) []Role {
+	roles := []Role{RoleUser}
+	switch accountType {
+	case AccountTypeStaff:
+		roles = append(roles, RoleStaff)
+	case AccountTypeAdmin:
+		roles = append(roles, RoleStaff, RoleAdmin)
+		if userLevel >= UserLevelSuperAdmin {
+			roles = append(roles, RoleSuperAdmin)
+		}
+	}
+	return roles
+}
+
+// HasAnyRole reports whether an account type and user level hold at least one of roles.
+func HasAnyRole(accountType, userLevel uint8, roles ...Role) bool {
+	for _, held := range Roles(accountType, userLevel) {
+		for _, role := range roles {
+			if held == role {
+				return true
+			}
+		}
+	}
+	return false
+}
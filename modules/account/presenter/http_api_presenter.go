@@ -0,0 +1,138 @@
+package presenter
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/middleware"
+	apiKeyModel "github.com/roysitumorang/bracha/modules/apikey/model"
+	"github.com/roysitumorang/bracha/services/identity"
+	"go.uber.org/zap"
+)
+
+type (
+	accountAPIHandler struct {
+		identityProvider identity.IdentityProvider
+	}
+)
+
+func NewAPI(
+	identityProvider identity.IdentityProvider,
+) *accountAPIHandler {
+	return &accountAPIHandler{
+		identityProvider: identityProvider,
+	}
+}
+
+func (q *accountAPIHandler) Mount(r fiber.Router) {
+	bearerAuth := middleware.BearerAuth(q.identityProvider)
+	canRead := middleware.RequirePermission(apiKeyModel.PermissionAccountRead)
+	canWrite := middleware.RequirePermission(apiKeyModel.PermissionAccountWrite)
+	r.Post("/login", canWrite, q.login).
+		Post("/logout", canWrite, bearerAuth, q.logout).
+		Get("/me", canRead, bearerAuth, q.me)
+}
+
+// login godoc
+//
+//	@Summary		Login
+//	@Description	Exchange credentials for a JWT from the identity provider
+//	@Tags			account
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		identity.LoginRequest	true	"Credentials"
+//	@Success		201		{object}	helper.Response{data=identity.UserLoginResponse}
+//	@Failure		400		{object}	helper.Response
+//	@Failure		401		{object}	helper.Response
+//	@Failure		403		{object}	helper.Response
+//	@Failure		423		{object}	helper.Response
+//	@Failure		429		{object}	helper.Response
+//	@Failure		502		{object}	helper.Response
+//	@Failure		503		{object}	helper.Response
+//	@Router			/account/login [post]
+func (q *accountAPIHandler) login(c *fiber.Ctx) error {
+	ctxt := "AccountAPIPresenter-login"
+	ctx := c.UserContext()
+	var request identity.LoginRequest
+	if err := c.BodyParser(&request); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrBodyParser")
+		return helper.NewResponse(fiber.StatusBadRequest).SetMessage(err.Error()).WriteResponse(c)
+	}
+	if request.Login == "" || request.Password == "" {
+		return helper.NewResponse(fiber.StatusBadRequest).SetMessage("login and password are required").WriteResponse(c)
+	}
+	response, err := q.identityProvider.Login(ctx, request.Login, request.Password)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrLogin")
+		return helper.NewResponse(loginErrorStatusCode(err)).SetMessage(identity.UserMessage(err)).WriteResponse(c)
+	}
+	if company, ok := middleware.CurrentCompany(ctx); ok && !company.Allows(response.Data.User.CompanyID) {
+		if err = q.identityProvider.Logout(ctx, response.Data.IDToken); err != nil {
+			helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrLogout")
+		}
+		return helper.NewResponse(fiber.StatusForbidden).SetMessage("Forbidden").WriteResponse(c)
+	}
+	return helper.NewResponse(fiber.StatusCreated).SetData(response.Data).WriteResponse(c)
+}
+
+// logout godoc
+//
+//	@Summary		Logout
+//	@Description	Revoke the bearer's session at the identity provider
+//	@Tags			account
+//	@Produce		json
+//	@Security		ApiKeyAuth && BearerAuth
+//	@Success		200	{object}	helper.Response
+//	@Failure		401	{object}	helper.Response
+//	@Failure		403	{object}	helper.Response
+//	@Failure		502	{object}	helper.Response
+//	@Router			/account/logout [post]
+func (q *accountAPIHandler) logout(c *fiber.Ctx) error {
+	ctxt := "AccountAPIPresenter-logout"
+	ctx := c.UserContext()
+	jwt, _ := middleware.CurrentJwt(ctx)
+	if err := q.identityProvider.Logout(ctx, jwt); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrLogout")
+		return helper.NewResponse(fiber.StatusBadGateway).SetMessage(identity.UserMessage(err)).WriteResponse(c)
+	}
+	return helper.NewResponse(fiber.StatusOK).SetMessage("Logged out").WriteResponse(c)
+}
+
+// me godoc
+//
+//	@Summary		Current user
+//	@Description	Profile of the bearer
+//	@Tags			account
+//	@Produce		json
+//	@Security		ApiKeyAuth && BearerAuth
+//	@Success		200	{object}	helper.Response{data=identity.User}
+//	@Failure		401	{object}	helper.Response
+//	@Failure		403	{object}	helper.Response
+//	@Router			/account/me [get]
+func (q *accountAPIHandler) me(c *fiber.Ctx) error {
+	currentUser, _ := middleware.CurrentUser(c.UserContext())
+	return helper.NewResponse(fiber.StatusOK).SetData(currentUser).WriteResponse(c)
+}
+
+// loginErrorStatusCode maps a failed login to the status code API clients get.
+func loginErrorStatusCode(err error) int {
+	switch {
+	case errors.Is(err, identity.ErrInvalidCredentials):
+		return fiber.StatusUnauthorized
+	case errors.Is(err, identity.ErrAccountLocked):
+		return fiber.StatusLocked
+	case errors.Is(err, identity.ErrAccountUnconfirmed),
+		errors.Is(err, identity.ErrAccountDeactivated):
+		return fiber.StatusForbidden
+	case errors.Is(err, identity.ErrRateLimited):
+		return fiber.StatusTooManyRequests
+	case errors.Is(err, identity.ErrUnavailable):
+		return fiber.StatusServiceUnavailable
+	}
+	var sadiaErr *identity.Error
+	if errors.As(err, &sadiaErr) && sadiaErr.StatusCode < fiber.StatusInternalServerError {
+		return sadiaErr.StatusCode
+	}
+	return fiber.StatusBadGateway
+}
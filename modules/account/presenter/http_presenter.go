@@ -1,30 +1,56 @@
 package presenter
 
 import (
+	"errors"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/session"
 	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/middleware"
 	"github.com/roysitumorang/bracha/models"
-	serviceSadia "github.com/roysitumorang/bracha/services/sadia"
+	"github.com/roysitumorang/bracha/services/identity"
 	"go.uber.org/zap"
 )
 
+const (
+	minPasswordLength            = 8
+	passwordResetRequestInterval = time.Minute
+)
+
+var (
+	usernameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.]{3,32}$`)
+	phoneRegex    = regexp.MustCompile(`^\+?[0-9]{8,15}$`)
+)
+
 type (
 	accountHTTPHandler struct {
-		sessionStore *session.Store
-		serviceSadia *serviceSadia.ServiceSadia
+		sessionStore     *session.Store
+		identityProvider identity.IdentityProvider
+		passwordResetter identity.PasswordResetter
+		contactConfirmer identity.ContactConfirmer
+		resolveCompany   fiber.Handler
 	}
 )
 
 func New(
 	sessionStore *session.Store,
-	serviceSadia *serviceSadia.ServiceSadia,
+	identityProvider identity.IdentityProvider,
+	resolveCompany fiber.Handler,
 ) *accountHTTPHandler {
+	// password resets and confirmations are only offered when the provider supports them
+	passwordResetter, _ := identityProvider.(identity.PasswordResetter)
+	contactConfirmer, _ := identityProvider.(identity.ContactConfirmer)
 	return &accountHTTPHandler{
-		sessionStore: sessionStore,
-		serviceSadia: serviceSadia,
+		sessionStore:     sessionStore,
+		identityProvider: identityProvider,
+		passwordResetter: passwordResetter,
+		contactConfirmer: contactConfirmer,
+		resolveCompany:   resolveCompany,
 	}
 }
 
@@ -33,8 +59,33 @@ func (q *accountHTTPHandler) Mount(r fiber.Router) {
 	r.Group("/login").
 		Get("", q.login).
 		Post("", q.doLogin)
-	r.Group("/me").
-		Get("/about", q.aboutCurrentUser)
+	r.Group("/register").
+		Get("", q.register).
+		Post("", q.doRegister)
+	if q.passwordResetter != nil {
+		r.Group("/password").
+			Get("/forgot", q.forgotPassword).
+			Post("/forgot", q.doForgotPassword).
+			Get("/reset/:token", q.resetPassword).
+			Post("/reset/:token", q.doResetPassword)
+	}
+	me := r.Group("/me", middleware.RequireLogin(q.sessionStore, q.identityProvider), q.resolveCompany, middleware.LoadCompanies(q.sessionStore, q.identityProvider)).
+		Get("/about", q.aboutCurrentUser).
+		Post("/company", q.switchCompany).
+		Get("/profile", q.profile).
+		Post("/profile", q.updateProfile).
+		Get("/password", q.changePassword).
+		Post("/password", q.doChangePassword).
+		Get("/sessions", q.sessions).
+		Post("/sessions/revoke-others", q.revokeOtherSessions).
+		Post("/sessions/:id/revoke", q.revokeSession)
+	if q.contactConfirmer != nil {
+		me.
+			Get("/confirmation", q.confirmation).
+			Post("/confirmation/email", q.sendEmailConfirmation).
+			Post("/confirmation/phone", q.sendPhoneConfirmation).
+			Post("/confirmation/phone/verify", q.confirmPhone)
+	}
 }
 
 func (q *accountHTTPHandler) logout(c *fiber.Ctx) error {
@@ -45,11 +96,20 @@ func (q *accountHTTPHandler) logout(c *fiber.Ctx) error {
 		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGet")
 		return c.SendString(err.Error())
 	}
+	// an impersonating admin leaves through the admin area, which audits the end of the impersonation;
+	// it only accepts POSTs, so the admin is asked to confirm rather than redirected there
+	if isImpersonating, ok := session.Get(models.IsImpersonating).(bool); ok && isImpersonating {
+		return c.Render("account/impersonation", fiber.Map{})
+	}
+	if jwt, ok := session.Get(models.CurrentJwt).(string); ok && jwt != "" {
+		if err = q.identityProvider.Logout(ctx, jwt); err != nil {
+			helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrLogout")
+		}
+	}
 	if err = session.Destroy(); err != nil {
 		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrDestroy")
 		return c.SendString(err.Error())
 	}
-	c.ClearCookie()
 	return c.Redirect("/account/login")
 }
 
@@ -61,12 +121,23 @@ func (q *accountHTTPHandler) login(c *fiber.Ctx) error {
 		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGet")
 		return c.SendString(err.Error())
 	}
+	next := helper.SafeRedirectPath(c.Query("next"))
+	if next == "" {
+		next = "/account/me/about"
+	}
 	if isAuthenticated, ok := session.Get(models.IsAuthenticated).(bool); ok && isAuthenticated {
-		return c.Redirect("/account/me/about")
+		return c.Redirect(next)
+	}
+	message, err := middleware.PopFlash(session)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrPopFlash")
+		return c.SendString(err.Error())
 	}
 	return c.Render("account/login", fiber.Map{
-		"message": "",
-		"login":   "",
+		"message":          message,
+		"login":            "",
+		"next":             next,
+		"canResetPassword": q.passwordResetter != nil,
 	})
 }
 
@@ -78,50 +149,742 @@ func (q *accountHTTPHandler) doLogin(c *fiber.Ctx) error {
 		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGet")
 		return c.SendString(err.Error())
 	}
+	next := helper.SafeRedirectPath(c.FormValue("next"))
+	if next == "" {
+		next = "/account/me/about"
+	}
 	if isAuthenticated, ok := session.Get(models.IsAuthenticated).(bool); ok && isAuthenticated {
-		return c.Redirect("/account/me/about")
+		return c.Redirect(next)
 	}
-	response, err := q.serviceSadia.Login(ctx, c.FormValue("login"), c.FormValue("password"))
+	response, err := q.identityProvider.Login(ctx, c.FormValue("login"), c.FormValue("password"))
 	if err != nil {
 		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrLogin")
 		return c.Render("account/login", fiber.Map{
-			"message": err.Error(),
-			"login":   c.FormValue("login"),
+			"message":          identity.UserMessage(err),
+			"login":            c.FormValue("login"),
+			"next":             next,
+			"canResetPassword": q.passwordResetter != nil,
 		})
 	}
-	if response.StatusCode != fiber.StatusCreated {
-		return c.Render("account/login", fiber.Map{
-			"message": response.Message,
-			"login":   c.FormValue("login"),
+	if err = q.authenticate(session, response.Data, ""); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrAuthenticate")
+		return c.SendString(err.Error())
+	}
+	return c.Redirect(next)
+}
+
+func (q *accountHTTPHandler) register(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-register"
+	ctx := c.UserContext()
+	session, err := q.sessionStore.Get(c)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGet")
+		return c.SendString(err.Error())
+	}
+	if isAuthenticated, ok := session.Get(models.IsAuthenticated).(bool); ok && isAuthenticated {
+		return c.Redirect("/account/me/about")
+	}
+	return c.Render("account/register", fiber.Map{
+		"message":  "",
+		"errors":   fiber.Map{},
+		"name":     "",
+		"username": "",
+		"email":    "",
+		"phone":    "",
+	})
+}
+
+func (q *accountHTTPHandler) doRegister(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-doRegister"
+	ctx := c.UserContext()
+	session, err := q.sessionStore.Get(c)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGet")
+		return c.SendString(err.Error())
+	}
+	if isAuthenticated, ok := session.Get(models.IsAuthenticated).(bool); ok && isAuthenticated {
+		return c.Redirect("/account/me/about")
+	}
+	name := strings.TrimSpace(c.FormValue("name"))
+	username := strings.TrimSpace(c.FormValue("username"))
+	email := strings.TrimSpace(c.FormValue("email"))
+	phone := strings.TrimSpace(c.FormValue("phone"))
+	password := c.FormValue("password")
+	passwordConfirmation := c.FormValue("password_confirmation")
+	data := fiber.Map{
+		"message":  "",
+		"name":     name,
+		"username": username,
+		"email":    email,
+		"phone":    phone,
+	}
+	fieldErrors := validateProfile(name, username, email, phone)
+	if len(password) < minPasswordLength {
+		fieldErrors["password"] = "Password must be at least 8 characters"
+	}
+	if passwordConfirmation != password {
+		fieldErrors["password_confirmation"] = "Password confirmation doesn't match"
+	}
+	data["errors"] = fieldErrors
+	if len(fieldErrors) > 0 {
+		return c.Status(fiber.StatusUnprocessableEntity).Render("account/register", data)
+	}
+	request := identity.RegisterRequest{
+		Name:     name,
+		Username: username,
+		Password: password,
+	}
+	if email != "" {
+		request.Email = &email
+	}
+	if phone != "" {
+		request.Phone = &phone
+	}
+	response, err := q.identityProvider.Register(ctx, request)
+	if err == nil {
+		err = response.Expect(fiber.StatusCreated)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrRegister")
+		data["message"] = identity.UserMessage(err)
+		for field, message := range identity.FieldErrors(err) {
+			fieldErrors[field] = message
+		}
+		return c.Render("account/register", data)
+	}
+	if err = q.authenticate(session, response.Data, ""); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrAuthenticate")
+		return c.SendString(err.Error())
+	}
+	return c.Redirect("/account/me/about")
+}
+
+func (q *accountHTTPHandler) forgotPassword(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-forgotPassword"
+	ctx := c.UserContext()
+	session, err := q.sessionStore.Get(c)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGet")
+		return c.SendString(err.Error())
+	}
+	if isAuthenticated, ok := session.Get(models.IsAuthenticated).(bool); ok && isAuthenticated {
+		return c.Redirect("/account/me/about")
+	}
+	message, err := middleware.PopFlash(session)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrPopFlash")
+		return c.SendString(err.Error())
+	}
+	return c.Render("account/password/forgot", fiber.Map{
+		"message": message,
+		"login":   "",
+	})
+}
+
+func (q *accountHTTPHandler) doForgotPassword(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-doForgotPassword"
+	ctx := c.UserContext()
+	session, err := q.sessionStore.Get(c)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGet")
+		return c.SendString(err.Error())
+	}
+	if isAuthenticated, ok := session.Get(models.IsAuthenticated).(bool); ok && isAuthenticated {
+		return c.Redirect("/account/me/about")
+	}
+	login := strings.TrimSpace(c.FormValue("login"))
+	if login == "" {
+		return c.Render("account/password/forgot", fiber.Map{
+			"message": "Login is required",
+			"login":   login,
 		})
 	}
-	session.Set(models.IsAuthenticated, true)
-	session.Set(models.CurrentUser, response.Data.User)
-	session.Set(models.CurrentJwt, response.Data.IDToken)
-	session.SetExpiry(time.Until(response.Data.ExpiredAt))
+	throttled, err := q.throttlePasswordReset(login)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrThrottlePasswordReset")
+		return c.SendString(err.Error())
+	}
+	if throttled {
+		return c.Status(fiber.StatusTooManyRequests).Render("account/password/forgot", fiber.Map{
+			"message": "Please wait a moment before requesting another password reset",
+			"login":   login,
+		})
+	}
+	response, err := q.passwordResetter.ForgotPassword(ctx, login)
+	if err == nil {
+		err = response.Expect(fiber.StatusOK)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrForgotPassword")
+		// anything but an outage gets the generic message below, so callers can't probe which accounts exist
+		if errors.Is(err, identity.ErrUnavailable) || errors.Is(err, identity.ErrRateLimited) {
+			return c.Render("account/password/forgot", fiber.Map{
+				"message": identity.UserMessage(err),
+				"login":   login,
+			})
+		}
+	}
+	session.Set(models.FlashMessage, "If the account exists, password reset instructions have been sent")
 	if err = session.Save(); err != nil {
 		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
 		return c.SendString(err.Error())
 	}
-	return c.Redirect("/account/me/about")
+	return c.Redirect("/account/password/forgot")
 }
 
-func (q *accountHTTPHandler) aboutCurrentUser(c *fiber.Ctx) error {
-	ctxt := "AccountPresenter-aboutCurrentUser"
+// throttlePasswordReset reports whether a password reset was already requested for login
+// within passwordResetRequestInterval, and otherwise records this request. It is keyed on the
+// login alone, since behind a proxy the caller's IP is the proxy's, and kept in the session
+// storage rather than the session so dropping the cookie doesn't bypass it.
+func (q *accountHTTPHandler) throttlePasswordReset(login string) (bool, error) {
+	key := models.PasswordResetLoginPrefix + strings.ToLower(login)
+	requestedAt, err := q.sessionStore.Storage.Get(key)
+	if err != nil {
+		return false, err
+	}
+	if len(requestedAt) > 0 {
+		return true, nil
+	}
+	return false, q.sessionStore.Storage.Set(key, []byte{1}, passwordResetRequestInterval)
+}
+
+func (q *accountHTTPHandler) resetPassword(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-resetPassword"
+	ctx := c.UserContext()
+	session, err := q.sessionStore.Get(c)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGet")
+		return c.SendString(err.Error())
+	}
+	if isAuthenticated, ok := session.Get(models.IsAuthenticated).(bool); ok && isAuthenticated {
+		return c.Redirect("/account/me/about")
+	}
+	return c.Render("account/password/reset", fiber.Map{
+		"message": "",
+		"token":   c.Params("token"),
+	})
+}
+
+func (q *accountHTTPHandler) doResetPassword(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-doResetPassword"
 	ctx := c.UserContext()
 	session, err := q.sessionStore.Get(c)
 	if err != nil {
 		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGet")
 		return c.SendString(err.Error())
 	}
-	if isAuthenticated, ok := session.Get(models.IsAuthenticated).(bool); !ok || !isAuthenticated {
-		return c.Redirect("/account/login")
+	if isAuthenticated, ok := session.Get(models.IsAuthenticated).(bool); ok && isAuthenticated {
+		return c.Redirect("/account/me/about")
+	}
+	token := c.Params("token")
+	password := c.FormValue("password")
+	if len(password) < minPasswordLength {
+		return c.Status(fiber.StatusUnprocessableEntity).Render("account/password/reset", fiber.Map{
+			"message": "Password must be at least 8 characters",
+			"token":   token,
+		})
+	}
+	if c.FormValue("password_confirmation") != password {
+		return c.Status(fiber.StatusUnprocessableEntity).Render("account/password/reset", fiber.Map{
+			"message": "Password confirmation doesn't match",
+			"token":   token,
+		})
+	}
+	response, err := q.passwordResetter.ResetPassword(ctx, token, password)
+	if err == nil {
+		err = response.Expect(fiber.StatusOK)
 	}
-	currentUser, ok := session.Get(models.CurrentUser).(serviceSadia.User)
-	if !ok {
-		return c.SendStatus(fiber.StatusInternalServerError)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrResetPassword")
+		return c.Render("account/password/reset", fiber.Map{
+			"message": identity.UserMessage(err),
+			"token":   token,
+		})
 	}
+	session.Set(models.FlashMessage, "Your password has been reset, please login with your new password")
+	if err = session.Save(); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
+		return c.SendString(err.Error())
+	}
+	return c.Redirect("/account/login")
+}
+
+func (q *accountHTTPHandler) aboutCurrentUser(c *fiber.Ctx) error {
+	currentUser, _ := middleware.CurrentUser(c.UserContext())
 	return c.Render("account/me/about", fiber.Map{
 		"currentUser": currentUser,
+		"canConfirm":  q.contactConfirmer != nil,
 	})
 }
+
+func (q *accountHTTPHandler) switchCompany(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-switchCompany"
+	ctx := c.UserContext()
+	session := middleware.Session(c)
+	currentUser, _ := middleware.CurrentUser(ctx)
+	jwt, _ := middleware.CurrentJwt(ctx)
+	next := helper.SafeRedirectPath(c.FormValue("next"))
+	if next == "" {
+		next = "/account/me/about"
+	}
+	companyID := c.FormValue("company_id")
+	if companyID == middleware.SelectedCompanyID(session, currentUser) {
+		return c.Redirect(next)
+	}
+	companies, err := middleware.UserCompanies(c, q.sessionStore, q.identityProvider)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrUserCompanies")
+		return c.Status(identity.StatusCode(err)).SendString(identity.UserMessage(err))
+	}
+	if !slices.ContainsFunc(companies, func(company identity.Company) bool {
+		return company.ID == companyID
+	}) {
+		return fiber.ErrForbidden
+	}
+	response, err := q.identityProvider.SwitchCompany(ctx, jwt, companyID)
+	if err == nil {
+		err = response.Expect(fiber.StatusCreated)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSwitchCompany")
+		return c.Status(identity.StatusCode(err)).SendString(identity.UserMessage(err))
+	}
+	// the reissued token is scoped to companyID even when the profile still names the home company,
+	// so the choice is kept apart from the user, which later profile refreshes overwrite
+	if companyID == response.Data.User.CompanyID {
+		companyID = ""
+	}
+	if err = q.authenticate(session, response.Data, companyID); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrAuthenticate")
+		return c.SendString(err.Error())
+	}
+	return c.Redirect(next)
+}
+
+func (q *accountHTTPHandler) profile(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-profile"
+	ctx := c.UserContext()
+	session := middleware.Session(c)
+	currentUser, _ := middleware.CurrentUser(ctx)
+	message, err := middleware.PopFlash(session)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrPopFlash")
+		return c.SendString(err.Error())
+	}
+	var email, phone string
+	if currentUser.Email != nil {
+		email = *currentUser.Email
+	}
+	if currentUser.Phone != nil {
+		phone = *currentUser.Phone
+	}
+	return c.Render("account/me/profile", fiber.Map{
+		"message":  message,
+		"errors":   fiber.Map{},
+		"name":     currentUser.Name,
+		"username": currentUser.Username,
+		"email":    email,
+		"phone":    phone,
+	})
+}
+
+func (q *accountHTTPHandler) updateProfile(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-updateProfile"
+	ctx := c.UserContext()
+	session := middleware.Session(c)
+	name := strings.TrimSpace(c.FormValue("name"))
+	username := strings.TrimSpace(c.FormValue("username"))
+	email := strings.TrimSpace(c.FormValue("email"))
+	phone := strings.TrimSpace(c.FormValue("phone"))
+	data := fiber.Map{
+		"message":  "",
+		"name":     name,
+		"username": username,
+		"email":    email,
+		"phone":    phone,
+	}
+	fieldErrors := validateProfile(name, username, email, phone)
+	data["errors"] = fieldErrors
+	if len(fieldErrors) > 0 {
+		return c.Status(fiber.StatusUnprocessableEntity).Render("account/me/profile", data)
+	}
+	request := identity.UpdateProfileRequest{
+		Name:     name,
+		Username: username,
+	}
+	if email != "" {
+		request.Email = &email
+	}
+	if phone != "" {
+		request.Phone = &phone
+	}
+	jwt, _ := middleware.CurrentJwt(ctx)
+	response, err := q.identityProvider.UpdateProfile(ctx, jwt, request)
+	if err == nil {
+		err = response.Expect(fiber.StatusOK)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrUpdateProfile")
+		data["message"] = identity.UserMessage(err)
+		for field, message := range identity.FieldErrors(err) {
+			fieldErrors[field] = message
+		}
+		return c.Status(identity.StatusCode(err)).Render("account/me/profile", data)
+	}
+	session.Set(models.CurrentUser, response.Data)
+	session.Set(models.FlashMessage, "Your profile has been updated")
+	if err = session.Save(); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
+		return c.SendString(err.Error())
+	}
+	return c.Redirect("/account/me/profile")
+}
+
+func (q *accountHTTPHandler) changePassword(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-changePassword"
+	ctx := c.UserContext()
+	session := middleware.Session(c)
+	message, err := middleware.PopFlash(session)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrPopFlash")
+		return c.SendString(err.Error())
+	}
+	return c.Render("account/me/password", fiber.Map{
+		"message": message,
+	})
+}
+
+func (q *accountHTTPHandler) doChangePassword(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-doChangePassword"
+	ctx := c.UserContext()
+	session := middleware.Session(c)
+	currentPassword := c.FormValue("current_password")
+	newPassword := c.FormValue("new_password")
+	if currentPassword == "" {
+		return c.Status(fiber.StatusUnprocessableEntity).Render("account/me/password", fiber.Map{
+			"message": "Current password is required",
+		})
+	}
+	if len(newPassword) < minPasswordLength {
+		return c.Status(fiber.StatusUnprocessableEntity).Render("account/me/password", fiber.Map{
+			"message": "New password must be at least 8 characters",
+		})
+	}
+	if c.FormValue("new_password_confirmation") != newPassword {
+		return c.Status(fiber.StatusUnprocessableEntity).Render("account/me/password", fiber.Map{
+			"message": "New password confirmation doesn't match",
+		})
+	}
+	currentUser, _ := middleware.CurrentUser(ctx)
+	jwt, _ := middleware.CurrentJwt(ctx)
+	// the provider revokes every other session along with the old password,
+	// so they are listed beforehand to be unbound locally afterwards
+	sessions, err := q.identityProvider.ListSessions(ctx, jwt)
+	if err == nil {
+		err = sessions.Expect(fiber.StatusOK)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrListSessions")
+		return c.Render("account/me/password", fiber.Map{
+			"message": identity.UserMessage(err),
+		})
+	}
+	response, err := q.identityProvider.ChangePassword(ctx, jwt, currentPassword, newPassword)
+	if err == nil {
+		err = response.Expect(fiber.StatusOK)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrChangePassword")
+		return c.Render("account/me/password", fiber.Map{
+			"message": identity.UserMessage(err),
+		})
+	}
+	if err = q.unbindOtherSessions(currentUser, sessions.Data); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrUnbindOtherSessions")
+	}
+	if err = session.Regenerate(); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrRegenerate")
+		return c.SendString(err.Error())
+	}
+	if currentUser, ok := session.Get(models.CurrentUser).(identity.User); ok && currentUser.CurrentSessionID != nil {
+		expiredAt, _ := session.Get(models.CurrentJwtExpiredAt).(time.Time)
+		if err = q.bindProviderSession(*currentUser.CurrentSessionID, session.ID(), time.Until(expiredAt)); err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrBindProviderSession")
+		}
+	}
+	session.Set(models.FlashMessage, "Your password has been changed")
+	if err = session.Save(); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
+		return c.SendString(err.Error())
+	}
+	return c.Redirect("/account/me/password")
+}
+
+func (q *accountHTTPHandler) sessions(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-sessions"
+	ctx := c.UserContext()
+	session := middleware.Session(c)
+	currentUser, _ := middleware.CurrentUser(ctx)
+	message, err := middleware.PopFlash(session)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrPopFlash")
+		return c.SendString(err.Error())
+	}
+	var currentSessionID string
+	if currentUser.CurrentSessionID != nil {
+		currentSessionID = *currentUser.CurrentSessionID
+	}
+	jwt, _ := middleware.CurrentJwt(ctx)
+	response, err := q.identityProvider.ListSessions(ctx, jwt)
+	if err == nil {
+		err = response.Expect(fiber.StatusOK)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrListSessions")
+		return c.Render("account/me/sessions", fiber.Map{
+			"message":          identity.UserMessage(err),
+			"sessions":         []identity.Session{},
+			"currentSessionID": currentSessionID,
+		})
+	}
+	return c.Render("account/me/sessions", fiber.Map{
+		"message":          message,
+		"sessions":         response.Data,
+		"currentSessionID": currentSessionID,
+	})
+}
+
+func (q *accountHTTPHandler) revokeSession(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-revokeSession"
+	ctx := c.UserContext()
+	session := middleware.Session(c)
+	currentUser, _ := middleware.CurrentUser(ctx)
+	sessionID := c.Params("id")
+	if currentUser.CurrentSessionID != nil && *currentUser.CurrentSessionID == sessionID {
+		return c.Redirect("/account/logout")
+	}
+	jwt, _ := middleware.CurrentJwt(ctx)
+	message := "The device has been signed out"
+	response, err := q.identityProvider.RevokeSession(ctx, jwt, sessionID)
+	if err == nil {
+		err = response.Expect(fiber.StatusOK)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrRevokeSession")
+		message = identity.UserMessage(err)
+	} else if err = q.unbindProviderSession(sessionID); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrUnbindProviderSession")
+	}
+	session.Set(models.FlashMessage, message)
+	if err = session.Save(); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
+		return c.SendString(err.Error())
+	}
+	return c.Redirect("/account/me/sessions")
+}
+
+func (q *accountHTTPHandler) revokeOtherSessions(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-revokeOtherSessions"
+	ctx := c.UserContext()
+	session := middleware.Session(c)
+	currentUser, _ := middleware.CurrentUser(ctx)
+	jwt, _ := middleware.CurrentJwt(ctx)
+	message := "All other devices have been signed out"
+	sessions, err := q.identityProvider.ListSessions(ctx, jwt)
+	if err == nil {
+		err = sessions.Expect(fiber.StatusOK)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrListSessions")
+		message = identity.UserMessage(err)
+	} else if response, err := q.identityProvider.RevokeOtherSessions(ctx, jwt); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrRevokeOtherSessions")
+		message = identity.UserMessage(err)
+	} else if err = response.Expect(fiber.StatusOK); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrRevokeOtherSessions")
+		message = identity.UserMessage(err)
+	} else if err = q.unbindOtherSessions(currentUser, sessions.Data); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrUnbindOtherSessions")
+	}
+	session.Set(models.FlashMessage, message)
+	if err = session.Save(); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
+		return c.SendString(err.Error())
+	}
+	return c.Redirect("/account/me/sessions")
+}
+
+func (q *accountHTTPHandler) confirmation(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-confirmation"
+	ctx := c.UserContext()
+	session := middleware.Session(c)
+	currentUser, _ := middleware.CurrentUser(ctx)
+	message, err := middleware.PopFlash(session)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrPopFlash")
+		return c.SendString(err.Error())
+	}
+	jwt, _ := middleware.CurrentJwt(ctx)
+	response, err := q.identityProvider.Me(ctx, jwt)
+	if err == nil {
+		err = response.Expect(fiber.StatusOK)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrMe")
+	} else {
+		currentUser = response.Data
+		session.Set(models.CurrentUser, currentUser)
+		if err = session.Save(); err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
+			return c.SendString(err.Error())
+		}
+	}
+	return c.Render("account/me/confirmation", fiber.Map{
+		"message":     message,
+		"currentUser": currentUser,
+	})
+}
+
+func (q *accountHTTPHandler) sendEmailConfirmation(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-sendEmailConfirmation"
+	ctx := c.UserContext()
+	session := middleware.Session(c)
+	jwt, _ := middleware.CurrentJwt(ctx)
+	message := "Email confirmation has been sent"
+	response, err := q.contactConfirmer.SendEmailConfirmation(ctx, jwt)
+	if err == nil {
+		err = response.Expect(fiber.StatusOK)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSendEmailConfirmation")
+		message = identity.UserMessage(err)
+	}
+	session.Set(models.FlashMessage, message)
+	if err := session.Save(); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
+		return c.SendString(err.Error())
+	}
+	return c.Redirect("/account/me/confirmation")
+}
+
+func (q *accountHTTPHandler) sendPhoneConfirmation(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-sendPhoneConfirmation"
+	ctx := c.UserContext()
+	session := middleware.Session(c)
+	jwt, _ := middleware.CurrentJwt(ctx)
+	message := "Confirmation code has been sent to your phone"
+	response, err := q.contactConfirmer.SendPhoneConfirmation(ctx, jwt)
+	if err == nil {
+		err = response.Expect(fiber.StatusOK)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSendPhoneConfirmation")
+		message = identity.UserMessage(err)
+	}
+	session.Set(models.FlashMessage, message)
+	if err := session.Save(); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
+		return c.SendString(err.Error())
+	}
+	return c.Redirect("/account/me/confirmation")
+}
+
+func (q *accountHTTPHandler) confirmPhone(c *fiber.Ctx) error {
+	ctxt := "AccountPresenter-confirmPhone"
+	ctx := c.UserContext()
+	session := middleware.Session(c)
+	jwt, _ := middleware.CurrentJwt(ctx)
+	message := "Your phone has been confirmed"
+	if otp := strings.TrimSpace(c.FormValue("otp")); otp == "" {
+		message = "Confirmation code is required"
+	} else if response, err := q.contactConfirmer.ConfirmPhone(ctx, jwt, otp); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrConfirmPhone")
+		message = identity.UserMessage(err)
+	} else if err = response.Expect(fiber.StatusOK); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrConfirmPhone")
+		message = identity.UserMessage(err)
+	} else {
+		session.Set(models.CurrentUser, response.Data)
+	}
+	session.Set(models.FlashMessage, message)
+	if err := session.Save(); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
+		return c.SendString(err.Error())
+	}
+	return c.Redirect("/account/me/confirmation")
+}
+
+// authenticate signs data's user in, scoped to companyID, or to their home company when empty.
+func (q *accountHTTPHandler) authenticate(session *session.Session, data identity.UserLoginResponse, companyID string) error {
+	if companyID == "" {
+		session.Delete(models.CurrentCompanyID)
+	} else {
+		session.Set(models.CurrentCompanyID, companyID)
+	}
+	session.Set(models.IsAuthenticated, true)
+	session.Set(models.CurrentUser, data.User)
+	session.Set(models.CurrentJwt, data.IDToken)
+	session.Set(models.CurrentJwtExpiredAt, data.ExpiredAt)
+	session.SetExpiry(time.Until(data.ExpiredAt))
+	if data.User.CurrentSessionID != nil {
+		if err := q.bindProviderSession(*data.User.CurrentSessionID, session.ID(), time.Until(data.ExpiredAt)); err != nil {
+			return err
+		}
+	}
+	return session.Save()
+}
+
+// bindProviderSession remembers which local session belongs to an identity provider session,
+// so revoking the provider session remotely can also invalidate it locally.
+func (q *accountHTTPHandler) bindProviderSession(providerSessionID, sessionID string, expiry time.Duration) error {
+	return q.sessionStore.Storage.Set(models.ProviderSessionPrefix+providerSessionID, helper.String2ByteSlice(sessionID), expiry)
+}
+
+// unbindProviderSession destroys the local session bound to a revoked provider session.
+func (q *accountHTTPHandler) unbindProviderSession(providerSessionID string) error {
+	key := models.ProviderSessionPrefix + providerSessionID
+	sessionID, err := q.sessionStore.Storage.Get(key)
+	if err != nil || sessionID == nil {
+		return err
+	}
+	if err = q.sessionStore.Delete(string(sessionID)); err != nil {
+		return err
+	}
+	return q.sessionStore.Storage.Delete(key)
+}
+
+// unbindOtherSessions destroys the local sessions bound to every session but currentUser's own.
+func (q *accountHTTPHandler) unbindOtherSessions(currentUser identity.User, sessions []identity.Session) error {
+	var errs []error
+	for _, providerSession := range sessions {
+		if currentUser.CurrentSessionID != nil && *currentUser.CurrentSessionID == providerSession.ID {
+			continue
+		}
+		if err := q.unbindProviderSession(providerSession.ID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateProfile(name, username, email, phone string) fiber.Map {
+	fieldErrors := fiber.Map{}
+	if name == "" {
+		fieldErrors["name"] = "Name is required"
+	}
+	if !usernameRegex.MatchString(username) {
+		fieldErrors["username"] = "Username must be 3-32 letters, digits, dots or underscores"
+	}
+	if email == "" && phone == "" {
+		fieldErrors["email"] = "Email or phone is required"
+	}
+	if email != "" {
+		if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+			fieldErrors["email"] = "Email is invalid"
+		}
+	}
+	if phone != "" && !phoneRegex.MatchString(phone) {
+		fieldErrors["phone"] = "Phone is invalid"
+	}
+	return fieldErrors
+}
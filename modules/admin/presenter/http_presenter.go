@@ -0,0 +1,584 @@
+package presenter
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/google/uuid"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/middleware"
+	"github.com/roysitumorang/bracha/models"
+	auditModel "github.com/roysitumorang/bracha/modules/audit/model"
+	auditQuery "github.com/roysitumorang/bracha/modules/audit/query"
+	"github.com/roysitumorang/bracha/services/identity"
+	serviceSadia "github.com/roysitumorang/bracha/services/sadia"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var (
+	userSortFields = []string{
+		"name",
+		"username",
+		"email",
+		"phone",
+		"status",
+		"created_at",
+		"last_login_at",
+	}
+)
+
+type (
+	adminHTTPHandler struct {
+		sessionStore      *session.Store
+		adminSessionStore *session.Store
+		serviceSadia      *serviceSadia.ServiceSadia
+		auditQuery        auditQuery.AuditQuery
+		requireAdminLogin fiber.Handler
+		requireAdminRole  fiber.Handler
+		resolveCompany    fiber.Handler
+		userActions       map[string]userAction
+	}
+
+	userAction struct {
+		Label       string
+		auditAction string
+		do          func(ctx context.Context, jwt, userID string) (*serviceSadia.ResponseUser, error)
+	}
+)
+
+func New(
+	sessionStore *session.Store,
+	adminSessionStore *session.Store,
+	serviceSadia *serviceSadia.ServiceSadia,
+	auditQuery auditQuery.AuditQuery,
+	resolveCompany fiber.Handler,
+) *adminHTTPHandler {
+	return &adminHTTPHandler{
+		sessionStore:      sessionStore,
+		adminSessionStore: adminSessionStore,
+		serviceSadia:      serviceSadia,
+		auditQuery:        auditQuery,
+		requireAdminLogin: middleware.RequireAdminLogin(adminSessionStore),
+		requireAdminRole:  middleware.RequireAdminRole(models.RoleStaff),
+		resolveCompany:    resolveCompany,
+		userActions: map[string]userAction{
+			"unlock": {
+				Label:       "Unlock",
+				auditAction: auditModel.ActionUnlockUser,
+				do:          serviceSadia.UnlockUser,
+			},
+			"deactivate": {
+				Label:       "Deactivate",
+				auditAction: auditModel.ActionDeactivateUser,
+				do:          serviceSadia.DeactivateUser,
+			},
+			"reactivate": {
+				Label:       "Reactivate",
+				auditAction: auditModel.ActionReactivateUser,
+				do:          serviceSadia.ReactivateUser,
+			},
+			"reset-password": {
+				Label:       "Force password reset",
+				auditAction: auditModel.ActionForceResetPassword,
+				do:          serviceSadia.ForceResetPassword,
+			},
+			// confirmation only, submitted to impersonate
+			"impersonate": {
+				Label:       "Impersonate",
+				auditAction: auditModel.ActionImpersonateUser,
+			},
+		},
+	}
+}
+
+func (q *adminHTTPHandler) Mount(r fiber.Router) {
+	r.Get("/logout", q.logout)
+	r.Group("/login").
+		Get("", q.login).
+		Post("", q.doLogin)
+	r.Get("", q.requireAdminLogin, q.requireAdminRole, q.resolveCompany, q.dashboard)
+	requireAdmin := middleware.RequireAdminRole(models.RoleAdmin)
+	r.Group("/users", q.requireAdminLogin, q.requireAdminRole, q.resolveCompany).
+		Get("", q.users).
+		Post("/:id/impersonate", requireAdmin, q.impersonate).
+		Get("/:id/:action", requireAdmin, q.confirmUserAction).
+		Post("/:id/:action", requireAdmin, q.doUserAction)
+	r.Group("/impersonation", q.requireAdminLogin).
+		Post("/stop", q.stopImpersonation)
+	r.Group("/api", q.requireAdminLogin, q.requireAdminRole, q.resolveCompany).
+		Get("/users", q.listUsers)
+}
+
+func (q *adminHTTPHandler) logout(c *fiber.Ctx) error {
+	ctxt := "AdminPresenter-logout"
+	ctx := c.UserContext()
+	// the admin session is read first: a user session started before it would lend it its new ID
+	session, err := q.adminSessionStore.Get(c)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGet")
+		return c.SendString(err.Error())
+	}
+	if isImpersonating, ok := session.Get(models.IsImpersonating).(bool); ok && isImpersonating {
+		userSession, err := middleware.StoredSession(c, q.sessionStore, middleware.SessionCookie)
+		if err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrStoredSession")
+			return c.SendString(err.Error())
+		}
+		if userSession != nil {
+			if jwt, ok := userSession.Get(models.CurrentJwt).(string); ok && jwt != "" {
+				if err = q.serviceSadia.Logout(ctx, jwt); err != nil {
+					helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrLogout")
+				}
+			}
+			if err = userSession.Destroy(); err != nil {
+				helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrDestroy")
+				return c.SendString(err.Error())
+			}
+		}
+	}
+	if jwt, ok := session.Get(models.CurrentAdminJwt).(string); ok && jwt != "" {
+		if err = q.serviceSadia.AdminLogout(ctx, jwt); err != nil {
+			helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrAdminLogout")
+		}
+	}
+	if err = session.Destroy(); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrDestroy")
+		return c.SendString(err.Error())
+	}
+	return c.Redirect("/admin/login")
+}
+
+func (q *adminHTTPHandler) login(c *fiber.Ctx) error {
+	ctxt := "AdminPresenter-login"
+	ctx := c.UserContext()
+	session, err := q.adminSessionStore.Get(c)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGet")
+		return c.SendString(err.Error())
+	}
+	next := helper.SafeRedirectPath(c.Query("next"))
+	if next == "" {
+		next = "/admin"
+	}
+	if isAdminAuthenticated, ok := session.Get(models.IsAdminAuthenticated).(bool); ok && isAdminAuthenticated {
+		return c.Redirect(next)
+	}
+	return c.Render("admin/login", fiber.Map{
+		"message": "",
+		"login":   "",
+		"next":    next,
+	})
+}
+
+func (q *adminHTTPHandler) doLogin(c *fiber.Ctx) error {
+	ctxt := "AdminPresenter-doLogin"
+	ctx := c.UserContext()
+	session, err := q.adminSessionStore.Get(c)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGet")
+		return c.SendString(err.Error())
+	}
+	next := helper.SafeRedirectPath(c.FormValue("next"))
+	if next == "" {
+		next = "/admin"
+	}
+	if isAdminAuthenticated, ok := session.Get(models.IsAdminAuthenticated).(bool); ok && isAdminAuthenticated {
+		return c.Redirect(next)
+	}
+	response, err := q.serviceSadia.AdminLogin(ctx, c.FormValue("login"), c.FormValue("password"))
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrAdminLogin")
+		return c.Render("admin/login", fiber.Map{
+			"message": identity.UserMessage(err),
+			"login":   c.FormValue("login"),
+			"next":    next,
+		})
+	}
+	if !models.HasAnyRole(response.Data.User.AccountType, response.Data.User.UserLevel, models.RoleStaff) {
+		return c.Status(fiber.StatusForbidden).Render("admin/login", fiber.Map{
+			"message": "You don't have access to the admin area",
+			"login":   c.FormValue("login"),
+			"next":    next,
+		})
+	}
+	session.Set(models.IsAdminAuthenticated, true)
+	session.Set(models.CurrentAdmin, response.Data.User)
+	session.Set(models.CurrentAdminJwt, response.Data.IDToken)
+	session.Set(models.CurrentAdminJwtExpiredAt, response.Data.ExpiredAt)
+	if err = session.Save(); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
+		return c.SendString(err.Error())
+	}
+	return c.Redirect(next)
+}
+
+func (q *adminHTTPHandler) dashboard(c *fiber.Ctx) error {
+	currentAdmin, _ := middleware.CurrentAdmin(c.UserContext())
+	return c.Render("admin/dashboard", fiber.Map{
+		"currentAdmin": currentAdmin,
+	})
+}
+
+func (q *adminHTTPHandler) users(c *fiber.Ctx) error {
+	ctxt := "AdminPresenter-users"
+	ctx := c.UserContext()
+	currentAdmin, _ := middleware.CurrentAdmin(ctx)
+	jwt, _ := middleware.CurrentAdminJwt(ctx)
+	message, err := middleware.PopFlash(middleware.Session(c))
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrPopFlash")
+		return c.SendString(err.Error())
+	}
+	filter := userFilter(c)
+	company, _ := middleware.CurrentCompany(ctx)
+	if !company.CrossTenant {
+		filter.CompanyID = company.ID
+	}
+	data := fiber.Map{
+		"currentAdmin": currentAdmin,
+		"message":      message,
+		"filter":       filter,
+		"sortFields":   userSortFields,
+		"crossTenant":  company.CrossTenant,
+		"users":        []serviceSadia.User{},
+		"pagination":   serviceSadia.Pagination{},
+		"prevURL":      "",
+		"nextURL":      "",
+	}
+	response, err := q.serviceSadia.ListUsers(ctx, jwt, filter)
+	if err == nil {
+		err = response.Expect(fiber.StatusOK)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrListUsers")
+		data["message"] = identity.UserMessage(err)
+		return c.Render("admin/users/index", data)
+	}
+	pagination := response.Data.Pagination
+	data["users"] = response.Data.Users
+	data["pagination"] = pagination
+	if pagination.Page > 1 {
+		data["prevURL"] = usersURL(filter, pagination.Page-1)
+	}
+	if pagination.Page < pagination.TotalPages {
+		data["nextURL"] = usersURL(filter, pagination.Page+1)
+	}
+	return c.Render("admin/users/index", data)
+}
+
+func (q *adminHTTPHandler) confirmUserAction(c *fiber.Ctx) error {
+	ctxt := "AdminPresenter-confirmUserAction"
+	ctx := c.UserContext()
+	currentAdmin, _ := middleware.CurrentAdmin(ctx)
+	jwt, _ := middleware.CurrentAdminJwt(ctx)
+	action, ok := q.userActions[c.Params("action")]
+	if !ok {
+		return fiber.ErrNotFound
+	}
+	response, err := q.serviceSadia.GetUser(ctx, jwt, c.Params("id"))
+	if err == nil {
+		err = response.Expect(fiber.StatusOK)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGetUser")
+		return c.Status(identity.StatusCode(err)).SendString(identity.UserMessage(err))
+	}
+	if company, _ := middleware.CurrentCompany(ctx); !company.Allows(response.Data.CompanyID) {
+		return fiber.ErrNotFound
+	}
+	return c.Render("admin/users/confirm", fiber.Map{
+		"currentAdmin": currentAdmin,
+		"action":       action,
+		"actionPath":   c.Path(),
+		"user":         response.Data,
+	})
+}
+
+func (q *adminHTTPHandler) doUserAction(c *fiber.Ctx) error {
+	ctxt := "AdminPresenter-doUserAction"
+	ctx := c.UserContext()
+	session := middleware.Session(c)
+	currentAdmin, _ := middleware.CurrentAdmin(ctx)
+	jwt, _ := middleware.CurrentAdminJwt(ctx)
+	action, ok := q.userActions[c.Params("action")]
+	if !ok || action.do == nil {
+		return fiber.ErrNotFound
+	}
+	auditLog := auditModel.AuditLog{
+		ID:        uuid.NewString(),
+		ActorID:   currentAdmin.ID,
+		ActorName: currentAdmin.Name,
+		Action:    action.auditAction,
+		TargetID:  c.Params("id"),
+		IP:        c.IP(),
+	}
+	if err := q.authorizeUser(ctx, jwt, auditLog.TargetID); err != nil {
+		return err
+	}
+	err := q.audit(ctx, &auditLog, func() {
+		response, err := action.do(ctx, jwt, auditLog.TargetID)
+		if err == nil {
+			err = response.Expect(fiber.StatusOK)
+		}
+		if err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrDo")
+			auditLog.Message = identity.UserMessage(err)
+			return
+		}
+		auditLog.Succeeded = true
+		auditLog.TargetName = response.Data.Name
+		auditLog.Message = action.Label + " succeeded"
+	})
+	if err != nil {
+		auditLog.Message = action.Label + " aborted: it could not be audited"
+	}
+	session.Set(models.FlashMessage, auditLog.Message)
+	if err = session.Save(); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
+		return c.SendString(err.Error())
+	}
+	return c.Redirect("/admin/users")
+}
+
+func (q *adminHTTPHandler) impersonate(c *fiber.Ctx) error {
+	ctxt := "AdminPresenter-impersonate"
+	ctx := c.UserContext()
+	session := middleware.Session(c)
+	currentAdmin, _ := middleware.CurrentAdmin(ctx)
+	jwt, _ := middleware.CurrentAdminJwt(ctx)
+	auditLog := auditModel.AuditLog{
+		ID:        uuid.NewString(),
+		ActorID:   currentAdmin.ID,
+		ActorName: currentAdmin.Name,
+		Action:    auditModel.ActionImpersonateUser,
+		TargetID:  c.Params("id"),
+		IP:        c.IP(),
+	}
+	if err := q.authorizeUser(ctx, jwt, auditLog.TargetID); err != nil {
+		return err
+	}
+	userSession, err := q.sessionStore.Get(c)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGet")
+		return c.SendString(err.Error())
+	}
+	var response *serviceSadia.ResponseUserLogin
+	if err = q.audit(ctx, &auditLog, func() {
+		var err error
+		response, err = q.serviceSadia.ImpersonateUser(ctx, jwt, auditLog.TargetID)
+		if err == nil {
+			err = response.Expect(fiber.StatusCreated)
+		}
+		if err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrImpersonateUser")
+			auditLog.Message = identity.UserMessage(err)
+			return
+		}
+		auditLog.Succeeded = true
+		auditLog.TargetName = response.Data.User.Name
+		auditLog.Message = "Impersonation started"
+	}); err != nil {
+		auditLog.Message = "Impersonation aborted: it could not be audited"
+	}
+	if !auditLog.Succeeded {
+		session.Set(models.FlashMessage, auditLog.Message)
+		if err = session.Save(); err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
+			return c.SendString(err.Error())
+		}
+		return c.Redirect("/admin/users")
+	}
+	// the impersonated user replaces whoever was signed in to the user session, so their token is
+	// logged out and the session starts over under a new ID rather than being overwritten in place
+	if previousJwt, ok := userSession.Get(models.CurrentJwt).(string); ok && previousJwt != "" {
+		if err = q.serviceSadia.Logout(ctx, previousJwt); err != nil {
+			helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrLogout")
+		}
+	}
+	if err = userSession.Reset(); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrReset")
+		return c.SendString(err.Error())
+	}
+	// the impersonated user lives in the regular user session, leaving the admin session untouched
+	userSession.Set(models.IsImpersonating, true)
+	userSession.Set(models.IsAuthenticated, true)
+	userSession.Set(models.CurrentUser, response.Data.User)
+	userSession.Set(models.CurrentJwt, response.Data.IDToken)
+	userSession.Set(models.CurrentJwtExpiredAt, response.Data.ExpiredAt)
+	userSession.SetExpiry(time.Until(response.Data.ExpiredAt))
+	if err = userSession.Save(); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
+		return c.SendString(err.Error())
+	}
+	// flagged on the admin side too, so logging the admin out ends the impersonation
+	session.Set(models.IsImpersonating, true)
+	if err = session.Save(); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
+		return c.SendString(err.Error())
+	}
+	return c.Redirect("/account/me/about")
+}
+
+func (q *adminHTTPHandler) stopImpersonation(c *fiber.Ctx) error {
+	ctxt := "AdminPresenter-stopImpersonation"
+	ctx := c.UserContext()
+	session := middleware.Session(c)
+	session.Delete(models.IsImpersonating)
+	userSession, err := middleware.StoredSession(c, q.sessionStore, middleware.SessionCookie)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrStoredSession")
+		return c.SendString(err.Error())
+	}
+	var isImpersonating bool
+	if userSession != nil {
+		isImpersonating, _ = userSession.Get(models.IsImpersonating).(bool)
+	}
+	if !isImpersonating {
+		if err = session.Save(); err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
+			return c.SendString(err.Error())
+		}
+		return c.Redirect("/admin")
+	}
+	currentAdmin, _ := middleware.CurrentAdmin(ctx)
+	impersonatedUser, _ := userSession.Get(models.CurrentUser).(serviceSadia.User)
+	auditLog := auditModel.AuditLog{
+		ID:         uuid.NewString(),
+		ActorID:    currentAdmin.ID,
+		ActorName:  currentAdmin.Name,
+		Action:     auditModel.ActionStopImpersonation,
+		TargetID:   impersonatedUser.ID,
+		TargetName: impersonatedUser.Name,
+		IP:         c.IP(),
+		Succeeded:  true,
+		Message:    "Impersonation stopped",
+	}
+	if jwt, ok := userSession.Get(models.CurrentJwt).(string); ok && jwt != "" {
+		if err = q.serviceSadia.Logout(ctx, jwt); err != nil {
+			helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrLogout")
+		}
+	}
+	// unlike the actions going through audit, ending an impersonation only gives up access,
+	// so it goes ahead even when it can't be audited
+	if err = q.auditQuery.CreateAuditLog(ctx, &auditLog); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrCreateAuditLog")
+	}
+	if err = userSession.Destroy(); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrDestroy")
+		return c.SendString(err.Error())
+	}
+	session.Set(models.FlashMessage, auditLog.Message)
+	if err = session.Save(); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrSave")
+		return c.SendString(err.Error())
+	}
+	return c.Redirect("/admin/users")
+}
+
+func (q *adminHTTPHandler) listUsers(c *fiber.Ctx) error {
+	ctxt := "AdminPresenter-listUsers"
+	ctx := c.UserContext()
+	jwt, _ := middleware.CurrentAdminJwt(ctx)
+	filter := userFilter(c)
+	if company, _ := middleware.CurrentCompany(ctx); !company.CrossTenant {
+		filter.CompanyID = company.ID
+	}
+	response, err := q.serviceSadia.ListUsers(ctx, jwt, filter)
+	if err == nil {
+		err = response.Expect(fiber.StatusOK)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrListUsers")
+		return helper.NewResponse(identity.StatusCode(err)).SetMessage(identity.UserMessage(err)).WriteResponse(c)
+	}
+	return helper.NewResponse(fiber.StatusOK).SetData(response.Data).WriteResponse(c)
+}
+
+// audit records auditLog as pending before do performs the action, so that no admin action goes
+// through unaudited: when the record can't be written, do never runs and the error is returned.
+// do fills in the outcome, which then replaces the pending record.
+func (q *adminHTTPHandler) audit(ctx context.Context, auditLog *auditModel.AuditLog, do func()) error {
+	ctxt := "AdminPresenter-audit"
+	auditLog.Message = "Pending"
+	if err := q.auditQuery.CreateAuditLog(ctx, auditLog); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrCreateAuditLog")
+		return err
+	}
+	do()
+	if err := q.auditQuery.UpdateAuditLog(ctx, auditLog); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrUpdateAuditLog")
+	}
+	return nil
+}
+
+// authorizeUser makes sure userID belongs to a company the caller may act on.
+func (q *adminHTTPHandler) authorizeUser(ctx context.Context, jwt, userID string) error {
+	ctxt := "AdminPresenter-authorizeUser"
+	company, _ := middleware.CurrentCompany(ctx)
+	if company.CrossTenant {
+		return nil
+	}
+	response, err := q.serviceSadia.GetUser(ctx, jwt, userID)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGetUser")
+		return fiber.NewError(fiber.StatusBadGateway, identity.UserMessage(err))
+	}
+	if response.StatusCode != fiber.StatusOK || !company.Allows(response.Data.CompanyID) {
+		return fiber.ErrNotFound
+	}
+	return nil
+}
+
+func userFilter(c *fiber.Ctx) serviceSadia.UserFilter {
+	filter := serviceSadia.UserFilter{
+		Name:      strings.TrimSpace(c.Query("name")),
+		Username:  strings.TrimSpace(c.Query("username")),
+		Email:     strings.TrimSpace(c.Query("email")),
+		Phone:     strings.TrimSpace(c.Query("phone")),
+		CompanyID: strings.TrimSpace(c.Query("company_id")),
+		Status:    strings.TrimSpace(c.Query("status")),
+		Page:      c.QueryInt("page", 1),
+		Size:      c.QueryInt("size", defaultPageSize),
+	}
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Size < 1 || filter.Size > maxPageSize {
+		filter.Size = defaultPageSize
+	}
+	if sort := c.Query("sort"); slices.Contains(userSortFields, strings.TrimPrefix(sort, "-")) {
+		filter.Sort = sort
+	}
+	return filter
+}
+
+func usersURL(filter serviceSadia.UserFilter, page int) string {
+	urlValues := url.Values{}
+	for key, value := range map[string]string{
+		"name":       filter.Name,
+		"username":   filter.Username,
+		"email":      filter.Email,
+		"phone":      filter.Phone,
+		"company_id": filter.CompanyID,
+		"status":     filter.Status,
+		"sort":       filter.Sort,
+	} {
+		if value != "" {
+			urlValues.Set(key, value)
+		}
+	}
+	urlValues.Set("page", strconv.Itoa(page))
+	urlValues.Set("size", strconv.Itoa(filter.Size))
+	return "/admin/users?" + urlValues.Encode()
+}
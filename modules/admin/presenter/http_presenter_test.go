@@ -0,0 +1,79 @@
+package presenter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/middleware"
+	"github.com/roysitumorang/bracha/models"
+	serviceSadia "github.com/roysitumorang/bracha/services/sadia"
+)
+
+func TestLogoutWithOnlyAdminCookie(t *testing.T) {
+	helper.InitLogger()
+	var adminLogouts atomic.Int32
+	sadia := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == fiber.MethodPost && r.URL.Path == "/admin/logout" {
+			adminLogouts.Add(1)
+		}
+		w.WriteHeader(fiber.StatusNoContent)
+	}))
+	defer sadia.Close()
+	sadiaURL, _ := url.Parse(sadia.URL)
+	sessionStore := session.New(session.Config{
+		KeyLookup: "cookie:" + middleware.SessionCookie,
+	})
+	adminSessionStore := session.New(session.Config{
+		Storage:   sessionStore.Storage,
+		KeyLookup: "cookie:" + middleware.AdminSessionCookie,
+	})
+	app := fiber.New()
+	app.Get("/seed", func(c *fiber.Ctx) error {
+		session, err := adminSessionStore.Get(c)
+		if err != nil {
+			return err
+		}
+		session.Set(models.IsAdminAuthenticated, true)
+		session.Set(models.CurrentAdminJwt, "admin-jwt")
+		if err = session.Save(); err != nil {
+			return err
+		}
+		return c.SendString(session.ID())
+	})
+	New(sessionStore, adminSessionStore, serviceSadia.New(sadiaURL, serviceSadia.DefaultTimeout, 0), nil, nil).
+		Mount(app.Group("/admin", middleware.Impersonation(sessionStore, adminSessionStore)))
+
+	response, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/seed", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var adminCookie *http.Cookie
+	for _, cookie := range response.Cookies() {
+		if cookie.Name == middleware.AdminSessionCookie {
+			adminCookie = cookie
+		}
+	}
+	if adminCookie == nil {
+		t.Fatal("seeding set no admin cookie")
+	}
+	request := httptest.NewRequest(fiber.MethodGet, "/admin/logout", nil)
+	request.AddCookie(&http.Cookie{Name: adminCookie.Name, Value: adminCookie.Value})
+	if response, err = app.Test(request); err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != fiber.StatusFound {
+		t.Fatalf("status code = %d, want %d", response.StatusCode, fiber.StatusFound)
+	}
+	if data, err := sessionStore.Storage.Get(adminCookie.Value); err != nil || data != nil {
+		t.Errorf("stored admin session = %q, %v, want it gone", data, err)
+	}
+	if got := adminLogouts.Load(); got != 1 {
+		t.Errorf("AdminLogout calls = %d, want 1", got)
+	}
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/roysitumorang/bracha/helper"
+)
+
+const (
+	keyPrefix    = "brk"
+	prefixLength = 8
+	secretLength = 32
+)
+
+const (
+	PermissionAccountRead  = "account:read"
+	PermissionAccountWrite = "account:write"
+)
+
+var (
+	ErrNotFound = errors.New("api key not found")
+
+	Permissions = []string{
+		PermissionAccountRead,
+		PermissionAccountWrite,
+	}
+)
+
+type (
+	// APIKey authenticates a machine client for a single company. Only the SHA-256
+	// hash of the key is stored; the key itself is shown once on creation.
+	APIKey struct {
+		ID          string     `json:"id"`
+		Name        string     `json:"name"`
+		Prefix      string     `json:"prefix"`
+		KeyHash     string     `json:"-"`
+		CompanyID   string     `json:"company_id"`
+		Permissions []string   `json:"permissions"`
+		CreatedAt   time.Time  `json:"created_at"`
+		LastUsedAt  *time.Time `json:"last_used_at"`
+		RevokedAt   *time.Time `json:"revoked_at"`
+	}
+)
+
+// GenerateKey returns a new plaintext key in the form brk_<prefix>_<secret> along with its prefix.
+func GenerateKey() (key, prefix string) {
+	prefix = helper.RandomString(prefixLength)
+	var builder strings.Builder
+	_, _ = builder.WriteString(keyPrefix)
+	_, _ = builder.WriteString("_")
+	_, _ = builder.WriteString(prefix)
+	_, _ = builder.WriteString("_")
+	_, _ = builder.WriteString(helper.RandomString(secretLength))
+	return builder.String(), prefix
+}
+
+// HashKey returns the hex encoded SHA-256 of a plaintext key.
+func HashKey(key string) string {
+	sum := sha256.Sum256(helper.String2ByteSlice(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// HasPermissions reports whether the key holds every one of permissions.
+func (q *APIKey) HasPermissions(permissions ...string) bool {
+	for _, permission := range permissions {
+		if !slices.Contains(q.Permissions, permission) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,184 @@
+package query
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/roysitumorang/bracha/helper"
+	apiKeyModel "github.com/roysitumorang/bracha/modules/apikey/model"
+	"go.uber.org/zap"
+)
+
+type (
+	APIKeyQuery interface {
+		CreateAPIKey(ctx context.Context, request *apiKeyModel.APIKey) error
+		FindAPIKeys(ctx context.Context, companyID string) ([]*apiKeyModel.APIKey, error)
+		FindActiveAPIKeyByHash(ctx context.Context, keyHash string) (*apiKeyModel.APIKey, error)
+		TouchAPIKey(ctx context.Context, id string) error
+		RevokeAPIKey(ctx context.Context, id string) error
+	}
+
+	apiKeyQuery struct {
+		dbRead, dbWrite *pgxpool.Pool
+	}
+)
+
+func New(dbRead, dbWrite *pgxpool.Pool) APIKeyQuery {
+	return &apiKeyQuery{
+		dbRead:  dbRead,
+		dbWrite: dbWrite,
+	}
+}
+
+func (q *apiKeyQuery) CreateAPIKey(ctx context.Context, request *apiKeyModel.APIKey) error {
+	ctxt := "APIKeyQuery-CreateAPIKey"
+	if err := q.dbWrite.QueryRow(
+		ctx,
+		`INSERT INTO api_keys (
+			id
+			, name
+			, prefix
+			, key_hash
+			, company_id
+			, permissions
+		) VALUES (
+			$1
+			, $2
+			, $3
+			, $4
+			, $5
+			, $6
+		) RETURNING created_at`,
+		request.ID,
+		request.Name,
+		request.Prefix,
+		request.KeyHash,
+		request.CompanyID,
+		request.Permissions,
+	).Scan(&request.CreatedAt); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrScan")
+		return err
+	}
+	return nil
+}
+
+func (q *apiKeyQuery) FindAPIKeys(ctx context.Context, companyID string) ([]*apiKeyModel.APIKey, error) {
+	ctxt := "APIKeyQuery-FindAPIKeys"
+	rows, err := q.dbRead.Query(
+		ctx,
+		`SELECT
+			id
+			, name
+			, prefix
+			, key_hash
+			, company_id
+			, permissions
+			, created_at
+			, last_used_at
+			, revoked_at
+		FROM api_keys
+		WHERE $1 = '' OR company_id = $1
+		ORDER BY created_at DESC`,
+		companyID,
+	)
+	if err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrQuery")
+		return nil, err
+	}
+	defer rows.Close()
+	var response []*apiKeyModel.APIKey
+	for rows.Next() {
+		var apiKey apiKeyModel.APIKey
+		if err = rows.Scan(
+			&apiKey.ID,
+			&apiKey.Name,
+			&apiKey.Prefix,
+			&apiKey.KeyHash,
+			&apiKey.CompanyID,
+			&apiKey.Permissions,
+			&apiKey.CreatedAt,
+			&apiKey.LastUsedAt,
+			&apiKey.RevokedAt,
+		); err != nil {
+			helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrScan")
+			return nil, err
+		}
+		response = append(response, &apiKey)
+	}
+	if err = rows.Err(); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrErr")
+		return nil, err
+	}
+	return response, nil
+}
+
+func (q *apiKeyQuery) FindActiveAPIKeyByHash(ctx context.Context, keyHash string) (*apiKeyModel.APIKey, error) {
+	ctxt := "APIKeyQuery-FindActiveAPIKeyByHash"
+	var response apiKeyModel.APIKey
+	if err := q.dbRead.QueryRow(
+		ctx,
+		`SELECT
+			id
+			, name
+			, prefix
+			, key_hash
+			, company_id
+			, permissions
+			, created_at
+			, last_used_at
+			, revoked_at
+		FROM api_keys
+		WHERE key_hash = $1
+			AND revoked_at IS NULL`,
+		keyHash,
+	).Scan(
+		&response.ID,
+		&response.Name,
+		&response.Prefix,
+		&response.KeyHash,
+		&response.CompanyID,
+		&response.Permissions,
+		&response.CreatedAt,
+		&response.LastUsedAt,
+		&response.RevokedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apiKeyModel.ErrNotFound
+		}
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrScan")
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (q *apiKeyQuery) TouchAPIKey(ctx context.Context, id string) error {
+	ctxt := "APIKeyQuery-TouchAPIKey"
+	if _, err := q.dbWrite.Exec(
+		ctx,
+		`UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1`,
+		id,
+	); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrExec")
+		return err
+	}
+	return nil
+}
+
+func (q *apiKeyQuery) RevokeAPIKey(ctx context.Context, id string) error {
+	ctxt := "APIKeyQuery-RevokeAPIKey"
+	tag, err := q.dbWrite.Exec(
+		ctx,
+		`UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL`,
+		id,
+	)
+	if err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrExec")
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apiKeyModel.ErrNotFound
+	}
+	return nil
+}
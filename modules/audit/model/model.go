@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	ActionUnlockUser         = "unlock_user"
+	ActionDeactivateUser     = "deactivate_user"
+	ActionReactivateUser     = "reactivate_user"
+	ActionForceResetPassword = "force_reset_password"
+	ActionImpersonateUser    = "impersonate_user"
+	ActionStopImpersonation  = "stop_impersonation"
+)
+
+type (
+	// AuditLog records who did what to whom from the admin area.
+	AuditLog struct {
+		ID         string    `json:"id"`
+		ActorID    string    `json:"actor_id"`
+		ActorName  string    `json:"actor_name"`
+		Action     string    `json:"action"`
+		TargetID   string    `json:"target_id"`
+		TargetName string    `json:"target_name"`
+		IP         string    `json:"ip"`
+		Succeeded  bool      `json:"succeeded"`
+		Message    string    `json:"message"`
+		CreatedAt  time.Time `json:"created_at"`
+	}
+)
@@ -0,0 +1,89 @@
+package query
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/roysitumorang/bracha/helper"
+	auditModel "github.com/roysitumorang/bracha/modules/audit/model"
+	"go.uber.org/zap"
+)
+
+type (
+	AuditQuery interface {
+		CreateAuditLog(ctx context.Context, request *auditModel.AuditLog) error
+		UpdateAuditLog(ctx context.Context, request *auditModel.AuditLog) error
+	}
+
+	auditQuery struct {
+		dbWrite *pgxpool.Pool
+	}
+)
+
+func New(dbWrite *pgxpool.Pool) AuditQuery {
+	return &auditQuery{
+		dbWrite: dbWrite,
+	}
+}
+
+func (q *auditQuery) CreateAuditLog(ctx context.Context, request *auditModel.AuditLog) error {
+	ctxt := "AuditQuery-CreateAuditLog"
+	if err := q.dbWrite.QueryRow(
+		ctx,
+		`INSERT INTO audit_logs (
+			id
+			, actor_id
+			, actor_name
+			, action
+			, target_id
+			, target_name
+			, ip
+			, succeeded
+			, message
+		) VALUES (
+			$1
+			, $2
+			, $3
+			, $4
+			, $5
+			, $6
+			, $7
+			, $8
+			, $9
+		) RETURNING created_at`,
+		request.ID,
+		request.ActorID,
+		request.ActorName,
+		request.Action,
+		request.TargetID,
+		request.TargetName,
+		request.IP,
+		request.Succeeded,
+		request.Message,
+	).Scan(&request.CreatedAt); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrScan")
+		return err
+	}
+	return nil
+}
+
+// UpdateAuditLog records the outcome of the action a pending audit log was created for.
+func (q *auditQuery) UpdateAuditLog(ctx context.Context, request *auditModel.AuditLog) error {
+	ctxt := "AuditQuery-UpdateAuditLog"
+	if _, err := q.dbWrite.Exec(
+		ctx,
+		`UPDATE audit_logs SET
+			target_name = $2
+			, succeeded = $3
+			, message = $4
+		WHERE id = $1`,
+		request.ID,
+		request.TargetName,
+		request.Succeeded,
+		request.Message,
+	); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrExec")
+		return err
+	}
+	return nil
+}
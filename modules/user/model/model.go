@@ -0,0 +1,50 @@
+package model
+
+import (
+	"errors"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("user not found")
+
+	// UsernameRegex and PhoneRegex keep usernames, emails and phones apart, so any login
+	// names a single column: an @ makes it an email and a phone-shaped one a phone.
+	UsernameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.]{3,32}$`)
+	PhoneRegex    = regexp.MustCompile(`^\+?[0-9]{8,15}$`)
+)
+
+type (
+	// User is an account of the local identity provider.
+	User struct {
+		ID                  string
+		CompanyID           string
+		AccountType         uint8
+		UserLevel           uint8
+		Name                string
+		Username            string
+		Email               *string
+		Phone               *string
+		PasswordHash        string
+		LoginCount          uint
+		CurrentLoginAt      *time.Time
+		LastLoginAt         *time.Time
+		LoginFailedAttempts int
+		LoginLockedAt       *time.Time
+		LastPasswordChange  *time.Time
+		CreatedAt           time.Time
+		UpdatedAt           time.Time
+		DeactivatedAt       *time.Time
+	}
+
+	// Session is a login of a local user; its ID is the jti of the tokens issued for it.
+	Session struct {
+		ID         string
+		UserID     string
+		CreatedAt  time.Time
+		LastSeenAt time.Time
+		ExpiredAt  time.Time
+		RevokedAt  *time.Time
+	}
+)
@@ -0,0 +1,448 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/roysitumorang/bracha/helper"
+	userModel "github.com/roysitumorang/bracha/modules/user/model"
+	"go.uber.org/zap"
+)
+
+const (
+	userColumns = `id
+			, company_id
+			, account_type
+			, user_level
+			, name
+			, username
+			, email
+			, phone
+			, password_hash
+			, login_count
+			, current_login_at
+			, last_login_at
+			, login_failed_attempts
+			, login_locked_at
+			, last_password_change
+			, created_at
+			, updated_at
+			, deactivated_at`
+	sessionColumns = `id
+			, user_id
+			, created_at
+			, last_seen_at
+			, expired_at
+			, revoked_at`
+)
+
+type (
+	UserQuery interface {
+		CreateUser(ctx context.Context, request *userModel.User) error
+		FindUserByID(ctx context.Context, id string) (*userModel.User, error)
+		FindUserByLogin(ctx context.Context, login string) (*userModel.User, error)
+		FindTakenFields(ctx context.Context, exceptUserID, username string, email, phone *string) ([]string, error)
+		UpdateProfile(ctx context.Context, request *userModel.User) error
+		UpdatePassword(ctx context.Context, id, passwordHash string) error
+		RecordLoginFailure(ctx context.Context, id string, lockAfter int) (*time.Time, error)
+		RecordLogin(ctx context.Context, id string) error
+		CreateSession(ctx context.Context, request *userModel.Session) error
+		FindActiveSession(ctx context.Context, id string) (*userModel.Session, error)
+		FindActiveSessions(ctx context.Context, userID string) ([]*userModel.Session, error)
+		ExtendSession(ctx context.Context, id string, expiredAt time.Time) error
+		RevokeSession(ctx context.Context, userID, id string) error
+		RevokeOtherSessions(ctx context.Context, userID, exceptID string) error
+	}
+
+	userQuery struct {
+		dbRead, dbWrite *pgxpool.Pool
+	}
+)
+
+func New(dbRead, dbWrite *pgxpool.Pool) UserQuery {
+	return &userQuery{
+		dbRead:  dbRead,
+		dbWrite: dbWrite,
+	}
+}
+
+func (q *userQuery) CreateUser(ctx context.Context, request *userModel.User) error {
+	ctxt := "UserQuery-CreateUser"
+	if err := q.dbWrite.QueryRow(
+		ctx,
+		`INSERT INTO users (
+			id
+			, company_id
+			, account_type
+			, user_level
+			, name
+			, username
+			, email
+			, phone
+			, password_hash
+		) VALUES (
+			$1
+			, $2
+			, $3
+			, $4
+			, $5
+			, $6
+			, $7
+			, $8
+			, $9
+		) RETURNING created_at, updated_at`,
+		request.ID,
+		request.CompanyID,
+		request.AccountType,
+		request.UserLevel,
+		request.Name,
+		request.Username,
+		request.Email,
+		request.Phone,
+		request.PasswordHash,
+	).Scan(&request.CreatedAt, &request.UpdatedAt); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrScan")
+		return err
+	}
+	return nil
+}
+
+func (q *userQuery) FindUserByID(ctx context.Context, id string) (*userModel.User, error) {
+	ctxt := "UserQuery-FindUserByID"
+	response, err := scanUser(q.dbRead.QueryRow(
+		ctx,
+		`SELECT `+userColumns+`
+		FROM users
+		WHERE id = $1`,
+		id,
+	))
+	if err != nil && !errors.Is(err, userModel.ErrNotFound) {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrScanUser")
+	}
+	return response, err
+}
+
+func (q *userQuery) FindUserByLogin(ctx context.Context, login string) (*userModel.User, error) {
+	ctxt := "UserQuery-FindUserByLogin"
+	response, err := scanUser(q.dbRead.QueryRow(
+		ctx,
+		`SELECT `+userColumns+`
+		FROM users
+		WHERE `+loginColumn(login)+` = $1`,
+		login,
+	))
+	if err != nil && !errors.Is(err, userModel.ErrNotFound) {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrScanUser")
+	}
+	return response, err
+}
+
+func (q *userQuery) FindTakenFields(ctx context.Context, exceptUserID, username string, email, phone *string) ([]string, error) {
+	ctxt := "UserQuery-FindTakenFields"
+	var usernameTaken, emailTaken, phoneTaken bool
+	if err := q.dbRead.QueryRow(
+		ctx,
+		`SELECT
+			COALESCE(BOOL_OR(username = $2), false)
+			, COALESCE(BOOL_OR(email = $3), false)
+			, COALESCE(BOOL_OR(phone = $4), false)
+		FROM users
+		WHERE id::text <> $1
+			AND (username = $2 OR email = $3 OR phone = $4)`,
+		exceptUserID,
+		username,
+		email,
+		phone,
+	).Scan(&usernameTaken, &emailTaken, &phoneTaken); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrScan")
+		return nil, err
+	}
+	var response []string
+	for field, taken := range map[string]bool{
+		"username": usernameTaken,
+		"email":    emailTaken,
+		"phone":    phoneTaken,
+	} {
+		if taken {
+			response = append(response, field)
+		}
+	}
+	return response, nil
+}
+
+func (q *userQuery) UpdateProfile(ctx context.Context, request *userModel.User) error {
+	ctxt := "UserQuery-UpdateProfile"
+	if err := q.dbWrite.QueryRow(
+		ctx,
+		`UPDATE users SET
+			name = $2
+			, username = $3
+			, email = $4
+			, phone = $5
+			, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING updated_at`,
+		request.ID,
+		request.Name,
+		request.Username,
+		request.Email,
+		request.Phone,
+	).Scan(&request.UpdatedAt); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrScan")
+		return err
+	}
+	return nil
+}
+
+func (q *userQuery) UpdatePassword(ctx context.Context, id, passwordHash string) error {
+	ctxt := "UserQuery-UpdatePassword"
+	if _, err := q.dbWrite.Exec(
+		ctx,
+		`UPDATE users SET
+			password_hash = $2
+			, last_password_change = CURRENT_TIMESTAMP
+			, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`,
+		id,
+		passwordHash,
+	); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrExec")
+		return err
+	}
+	return nil
+}
+
+// RecordLoginFailure counts a failed login, locking the user once lockAfter consecutive
+// failures are reached, and returns when the user got locked, if at all.
+func (q *userQuery) RecordLoginFailure(ctx context.Context, id string, lockAfter int) (*time.Time, error) {
+	ctxt := "UserQuery-RecordLoginFailure"
+	var loginLockedAt *time.Time
+	if err := q.dbWrite.QueryRow(
+		ctx,
+		`UPDATE users SET
+			login_failed_attempts = login_failed_attempts + 1
+			, login_locked_at = CASE
+				WHEN login_failed_attempts + 1 >= $2 THEN CURRENT_TIMESTAMP
+				ELSE login_locked_at
+			END
+		WHERE id = $1
+		RETURNING login_locked_at`,
+		id,
+		lockAfter,
+	).Scan(&loginLockedAt); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrScan")
+		return nil, err
+	}
+	return loginLockedAt, nil
+}
+
+func (q *userQuery) RecordLogin(ctx context.Context, id string) error {
+	ctxt := "UserQuery-RecordLogin"
+	if _, err := q.dbWrite.Exec(
+		ctx,
+		`UPDATE users SET
+			login_count = login_count + 1
+			, last_login_at = current_login_at
+			, current_login_at = CURRENT_TIMESTAMP
+			, login_failed_attempts = 0
+			, login_locked_at = NULL
+		WHERE id = $1`,
+		id,
+	); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrExec")
+		return err
+	}
+	return nil
+}
+
+func (q *userQuery) CreateSession(ctx context.Context, request *userModel.Session) error {
+	ctxt := "UserQuery-CreateSession"
+	if err := q.dbWrite.QueryRow(
+		ctx,
+		`INSERT INTO user_sessions (
+			id
+			, user_id
+			, expired_at
+		) VALUES (
+			$1
+			, $2
+			, $3
+		) RETURNING created_at, last_seen_at`,
+		request.ID,
+		request.UserID,
+		request.ExpiredAt,
+	).Scan(&request.CreatedAt, &request.LastSeenAt); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrScan")
+		return err
+	}
+	return nil
+}
+
+func (q *userQuery) FindActiveSession(ctx context.Context, id string) (*userModel.Session, error) {
+	ctxt := "UserQuery-FindActiveSession"
+	response, err := scanSession(q.dbRead.QueryRow(
+		ctx,
+		`SELECT `+sessionColumns+`
+		FROM user_sessions
+		WHERE id = $1
+			AND revoked_at IS NULL
+			AND expired_at > CURRENT_TIMESTAMP`,
+		id,
+	))
+	if err != nil && !errors.Is(err, userModel.ErrNotFound) {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrScanSession")
+	}
+	return response, err
+}
+
+func (q *userQuery) FindActiveSessions(ctx context.Context, userID string) ([]*userModel.Session, error) {
+	ctxt := "UserQuery-FindActiveSessions"
+	rows, err := q.dbRead.Query(
+		ctx,
+		`SELECT `+sessionColumns+`
+		FROM user_sessions
+		WHERE user_id = $1
+			AND revoked_at IS NULL
+			AND expired_at > CURRENT_TIMESTAMP
+		ORDER BY last_seen_at DESC`,
+		userID,
+	)
+	if err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrQuery")
+		return nil, err
+	}
+	defer rows.Close()
+	var response []*userModel.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrScanSession")
+			return nil, err
+		}
+		response = append(response, session)
+	}
+	if err = rows.Err(); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrErr")
+		return nil, err
+	}
+	return response, nil
+}
+
+func (q *userQuery) ExtendSession(ctx context.Context, id string, expiredAt time.Time) error {
+	ctxt := "UserQuery-ExtendSession"
+	if _, err := q.dbWrite.Exec(
+		ctx,
+		`UPDATE user_sessions SET
+			last_seen_at = CURRENT_TIMESTAMP
+			, expired_at = $2
+		WHERE id = $1`,
+		id,
+		expiredAt,
+	); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrExec")
+		return err
+	}
+	return nil
+}
+
+func (q *userQuery) RevokeSession(ctx context.Context, userID, id string) error {
+	ctxt := "UserQuery-RevokeSession"
+	tag, err := q.dbWrite.Exec(
+		ctx,
+		`UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id::text = $2
+			AND user_id = $1
+			AND revoked_at IS NULL`,
+		userID,
+		id,
+	)
+	if err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrExec")
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return userModel.ErrNotFound
+	}
+	return nil
+}
+
+func (q *userQuery) RevokeOtherSessions(ctx context.Context, userID, exceptID string) error {
+	ctxt := "UserQuery-RevokeOtherSessions"
+	if _, err := q.dbWrite.Exec(
+		ctx,
+		`UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1
+			AND id <> $2
+			AND revoked_at IS NULL`,
+		userID,
+		exceptID,
+	); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrExec")
+		return err
+	}
+	return nil
+}
+
+// loginColumn tells which of username, email or phone login is.
+func loginColumn(login string) string {
+	switch {
+	case strings.Contains(login, "@"):
+		return "email"
+	case userModel.PhoneRegex.MatchString(login):
+		return "phone"
+	default:
+		return "username"
+	}
+}
+
+func scanUser(row pgx.Row) (*userModel.User, error) {
+	var response userModel.User
+	if err := row.Scan(
+		&response.ID,
+		&response.CompanyID,
+		&response.AccountType,
+		&response.UserLevel,
+		&response.Name,
+		&response.Username,
+		&response.Email,
+		&response.Phone,
+		&response.PasswordHash,
+		&response.LoginCount,
+		&response.CurrentLoginAt,
+		&response.LastLoginAt,
+		&response.LoginFailedAttempts,
+		&response.LoginLockedAt,
+		&response.LastPasswordChange,
+		&response.CreatedAt,
+		&response.UpdatedAt,
+		&response.DeactivatedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, userModel.ErrNotFound
+		}
+		return nil, err
+	}
+	return &response, nil
+}
+
+func scanSession(row pgx.Row) (*userModel.Session, error) {
+	var response userModel.Session
+	if err := row.Scan(
+		&response.ID,
+		&response.UserID,
+		&response.CreatedAt,
+		&response.LastSeenAt,
+		&response.ExpiredAt,
+		&response.RevokedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, userModel.ErrNotFound
+		}
+		return nil, err
+	}
+	return &response, nil
+}
@@ -4,34 +4,202 @@ import (
 	"context"
 	"encoding/gob"
 	"errors"
+	"fmt"
+	"math"
 	"net/url"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/middleware"
+	userQuery "github.com/roysitumorang/bracha/modules/user/query"
+	"github.com/roysitumorang/bracha/services/identity"
+	serviceLocal "github.com/roysitumorang/bracha/services/local"
 	serviceSadia "github.com/roysitumorang/bracha/services/sadia"
 	"go.uber.org/zap"
 )
 
 type (
 	Service struct {
-		ServiceSadia *serviceSadia.ServiceSadia
+		DB               *pgxpool.Pool
+		IdentityProvider identity.IdentityProvider
+		// ServiceSadia and SadiaVerifier are nil unless IDENTITY_PROVIDER is sadia.
+		ServiceSadia       *serviceSadia.ServiceSadia
+		SadiaVerifier      *serviceSadia.Verifier
+		SadiaRefreshWindow time.Duration
+		TenantResolver     string
+		// TenantBaseDomain is the domain below which TenantResolver subdomain reads company IDs.
+		TenantBaseDomain string
 	}
 )
 
+const (
+	DefaultSadiaRefreshWindow = 5 * time.Minute
+	minLocalJWTSecretLength   = 32
+)
+
 func MakeHandler(ctx context.Context) (*Service, error) {
 	ctxt := "Router-MakeHandler"
+	db, err := NewDB(ctx)
+	if err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrNewDB")
+		return nil, err
+	}
+	sadiaRefreshWindow := DefaultSadiaRefreshWindow
+	if envSadiaRefreshWindow, ok := os.LookupEnv("SADIA_REFRESH_WINDOW"); ok && envSadiaRefreshWindow != "" {
+		if sadiaRefreshWindow, err = time.ParseDuration(envSadiaRefreshWindow); err != nil {
+			helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrParseDuration")
+			return nil, err
+		}
+	}
+	tenantResolver := middleware.TenantResolverSession
+	if envTenantResolver, ok := os.LookupEnv("TENANT_RESOLVER"); ok && envTenantResolver != "" {
+		tenantResolver = envTenantResolver
+	}
+	var tenantBaseDomain string
+	switch tenantResolver {
+	case middleware.TenantResolverSession:
+	case middleware.TenantResolverSubdomain:
+		if tenantBaseDomain = os.Getenv("TENANT_BASE_DOMAIN"); tenantBaseDomain == "" {
+			return nil, errors.New("env TENANT_BASE_DOMAIN is required when TENANT_RESOLVER is subdomain")
+		}
+	default:
+		return nil, fmt.Errorf("env TENANT_RESOLVER: unknown resolver %q", tenantResolver)
+	}
+	gob.Register(identity.User{})
+	gob.Register(time.Time{})
+	service := Service{
+		DB:                 db,
+		SadiaRefreshWindow: sadiaRefreshWindow,
+		TenantResolver:     tenantResolver,
+		TenantBaseDomain:   tenantBaseDomain,
+	}
+	identityProvider := identity.ProviderSadia
+	if envIdentityProvider, ok := os.LookupEnv("IDENTITY_PROVIDER"); ok && envIdentityProvider != "" {
+		identityProvider = envIdentityProvider
+	}
+	switch identityProvider {
+	case identity.ProviderSadia:
+		if service.ServiceSadia, service.SadiaVerifier, err = NewSadia(ctx); err != nil {
+			helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrNewSadia")
+			return nil, err
+		}
+		service.IdentityProvider = serviceSadia.NewProvider(service.ServiceSadia, service.SadiaVerifier)
+	case identity.ProviderLocal:
+		if service.IdentityProvider, err = NewLocal(db); err != nil {
+			helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrNewLocal")
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("env IDENTITY_PROVIDER: unknown provider %q", identityProvider)
+	}
+	return &service, nil
+}
+
+// NewSadia builds the Sadia client and its JWT verifier from the SADIA_* env.
+func NewSadia(ctx context.Context) (*serviceSadia.ServiceSadia, *serviceSadia.Verifier, error) {
+	ctxt := "Router-NewSadia"
 	envSadiaBaseURL, ok := os.LookupEnv("SADIA_BASE_URL")
 	if !ok || envSadiaBaseURL == "" {
-		return nil, errors.New("env SADIA_BASE_URL is required")
+		return nil, nil, errors.New("env SADIA_BASE_URL is required")
 	}
 	sadiaURL, err := url.Parse(envSadiaBaseURL)
 	if err != nil {
 		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrParse")
+		return nil, nil, err
+	}
+	sadiaTimeout := serviceSadia.DefaultTimeout
+	if envSadiaTimeout, ok := os.LookupEnv("SADIA_TIMEOUT"); ok && envSadiaTimeout != "" {
+		if sadiaTimeout, err = time.ParseDuration(envSadiaTimeout); err != nil {
+			helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrParseDuration")
+			return nil, nil, err
+		}
+	}
+	sadiaMaxRetries := serviceSadia.DefaultMaxRetries
+	if envSadiaMaxRetries, ok := os.LookupEnv("SADIA_MAX_RETRIES"); ok && envSadiaMaxRetries != "" {
+		if sadiaMaxRetries, err = strconv.Atoi(envSadiaMaxRetries); err != nil {
+			helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrAtoi")
+			return nil, nil, err
+		}
+		if sadiaMaxRetries < 0 {
+			return nil, nil, errors.New("env SADIA_MAX_RETRIES must not be negative")
+		}
+	}
+	sadiaJWKSURL := sadiaURL.JoinPath(serviceSadia.DefaultJWKSPath)
+	if envSadiaJWKSURL, ok := os.LookupEnv("SADIA_JWKS_URL"); ok && envSadiaJWKSURL != "" {
+		if sadiaJWKSURL, err = url.Parse(envSadiaJWKSURL); err != nil {
+			helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrParse")
+			return nil, nil, err
+		}
+	}
+	sadiaJWKSRefreshInterval := serviceSadia.DefaultJWKSRefreshInterval
+	if envSadiaJWKSRefreshInterval, ok := os.LookupEnv("SADIA_JWKS_REFRESH_INTERVAL"); ok && envSadiaJWKSRefreshInterval != "" {
+		if sadiaJWKSRefreshInterval, err = time.ParseDuration(envSadiaJWKSRefreshInterval); err != nil {
+			helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrParseDuration")
+			return nil, nil, err
+		}
+		if sadiaJWKSRefreshInterval <= 0 {
+			return nil, nil, errors.New("env SADIA_JWKS_REFRESH_INTERVAL must be positive")
+		}
+	}
+	envSadiaJWTIssuer, ok := os.LookupEnv("SADIA_JWT_ISSUER")
+	if !ok || envSadiaJWTIssuer == "" {
+		return nil, nil, errors.New("env SADIA_JWT_ISSUER is required")
+	}
+	envSadiaJWTAudience, ok := os.LookupEnv("SADIA_JWT_AUDIENCE")
+	if !ok || envSadiaJWTAudience == "" {
+		return nil, nil, errors.New("env SADIA_JWT_AUDIENCE is required")
+	}
+	sadiaVerifier := serviceSadia.NewVerifier(sadiaJWKSURL, envSadiaJWTIssuer, envSadiaJWTAudience, sadiaJWKSRefreshInterval)
+	return serviceSadia.New(sadiaURL, sadiaTimeout, sadiaMaxRetries), sadiaVerifier, nil
+}
+
+// NewLocal builds the identity provider backed by the users table from the LOCAL_* env.
+func NewLocal(db *pgxpool.Pool) (*serviceLocal.ServiceLocal, error) {
+	envLocalJWTSecret, ok := os.LookupEnv("LOCAL_JWT_SECRET")
+	if !ok || len(envLocalJWTSecret) < minLocalJWTSecretLength {
+		return nil, fmt.Errorf("env LOCAL_JWT_SECRET is required and must be at least %d characters", minLocalJWTSecretLength)
+	}
+	localJWTTTL := serviceLocal.DefaultTokenTTL
+	if envLocalJWTTTL, ok := os.LookupEnv("LOCAL_JWT_TTL"); ok && envLocalJWTTTL != "" {
+		var err error
+		if localJWTTTL, err = time.ParseDuration(envLocalJWTTTL); err != nil {
+			return nil, err
+		}
+	}
+	localCompanyID := serviceLocal.DefaultCompanyID
+	if envLocalCompanyID, ok := os.LookupEnv("LOCAL_COMPANY_ID"); ok && envLocalCompanyID != "" {
+		localCompanyID = envLocalCompanyID
+	}
+	return serviceLocal.New(userQuery.New(db, db), []byte(envLocalJWTSecret), localJWTTTL, localCompanyID)
+}
+
+func NewDB(ctx context.Context) (*pgxpool.Pool, error) {
+	ctxt := "Router-NewDB"
+	envDatabaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || envDatabaseURL == "" {
+		return nil, errors.New("env DATABASE_URL is required")
+	}
+	dbConfig, err := pgxpool.ParseConfig(envDatabaseURL)
+	if err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrParseConfig")
+		return nil, err
+	}
+	if envDBMaxConnections, ok := os.LookupEnv("DB_MAX_CONNECTIONS"); ok && envDBMaxConnections != "" {
+		if dbMaxConnections, _ := strconv.Atoi(envDBMaxConnections); dbMaxConnections > 0 && dbMaxConnections <= math.MaxInt32 {
+			dbConfig.MaxConns = int32(dbMaxConnections)
+		}
+	}
+	db, err := pgxpool.NewWithConfig(ctx, dbConfig)
+	if err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrNewWithConfig")
+		return nil, err
+	}
+	if err = db.Ping(ctx); err != nil {
+		helper.Capture(ctx, zap.ErrorLevel, err, ctxt, "ErrPing")
 		return nil, err
 	}
-	gob.Register(serviceSadia.User{})
-	serviceSadia := serviceSadia.New(sadiaURL)
-	return &Service{
-		ServiceSadia: serviceSadia,
-	}, nil
+	return db, nil
 }
@@ -28,6 +28,9 @@ import (
 	"github.com/roysitumorang/bracha/helper"
 	"github.com/roysitumorang/bracha/middleware"
 	accountPresenter "github.com/roysitumorang/bracha/modules/account/presenter"
+	adminPresenter "github.com/roysitumorang/bracha/modules/admin/presenter"
+	apiKeyQuery "github.com/roysitumorang/bracha/modules/apikey/query"
+	auditQuery "github.com/roysitumorang/bracha/modules/audit/query"
 	fiberSwagger "github.com/swaggo/fiber-swagger"
 	"go.uber.org/zap"
 )
@@ -44,12 +47,20 @@ func (q *Service) HTTPServerMain(ctx context.Context) error {
 		URL: os.Getenv("REDIS_URL"),
 	})
 	sessionStore := session.New(session.Config{
-		Storage: storage,
+		Storage:   storage,
+		KeyLookup: "cookie:" + middleware.SessionCookie,
+	})
+	// admins get their own cookie so user logouts and refreshes never touch the admin session
+	adminSessionStore := session.New(session.Config{
+		Storage:   storage,
+		KeyLookup: "cookie:" + middleware.AdminSessionCookie,
 	})
 	app := fiber.New(fiber.Config{
 		JSONEncoder: json.Marshal,
 		JSONDecoder: json.Unmarshal,
 		Views:       engine,
+		// lets middlewares such as Impersonation hand data to every view
+		PassLocalsToViews: true,
 		ErrorHandler: func(ctx *fiber.Ctx, err error) error {
 			statusCode := fiber.StatusInternalServerError
 			var e *fiber.Error
@@ -65,8 +76,14 @@ func (q *Service) HTTPServerMain(ctx context.Context) error {
 		}),
 		fiberzap.New(fiberzap.Config{
 			Logger: helper.GetLogger(),
+			FieldsFunc: func(c *fiber.Ctx) []zap.Field {
+				return []zap.Field{
+					zap.String("request_id", c.GetRespHeader(fiber.HeaderXRequestID)),
+				}
+			},
 		}),
 		requestid.New(),
+		middleware.RequestID(),
 		compress.New(),
 		rewrite.New(rewrite.Config{
 			Rules: map[string]string{},
@@ -74,6 +91,9 @@ func (q *Service) HTTPServerMain(ctx context.Context) error {
 		cors.New(),
 	)
 	basicAuth := middleware.BasicAuth()
+	metrics := monitor.New(monitor.Config{
+		APIOnly: true,
+	})
 	if helper.GetEnv() == "development" {
 		app.Get("/swagger/*", fiberSwagger.WrapHandler)
 	}
@@ -87,9 +107,22 @@ func (q *Service) HTTPServerMain(ctx context.Context) error {
 				"uptime":  time.Since(config.Now).String(),
 			}).WriteResponse(c)
 	}).
-		Get("/metrics", basicAuth, monitor.New(monitor.Config{
-			APIOnly: true,
-		})).
+		Get("/metrics", basicAuth, func(c *fiber.Ctx) error {
+			if err := metrics(c); err != nil {
+				return err
+			}
+			var data map[string]any
+			if err := json.Unmarshal(c.Response().Body(), &data); err != nil {
+				helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrUnmarshal")
+				return helper.NewResponse(fiber.StatusInternalServerError).SetMessage(err.Error()).WriteResponse(c)
+			}
+			if q.ServiceSadia != nil {
+				data["sadia"] = map[string]any{
+					"circuit_breaker": q.ServiceSadia.CircuitBreaker(),
+				}
+			}
+			return c.JSON(data)
+		}).
 		Get("/env", basicAuth, func(c *fiber.Ctx) error {
 			envMap, err := godotenv.Read(".env")
 			if err != nil {
@@ -99,7 +132,19 @@ func (q *Service) HTTPServerMain(ctx context.Context) error {
 			envMap["GO_VERSION"] = runtime.Version()
 			return helper.NewResponse(fiber.StatusOK).SetData(envMap).WriteResponse(c)
 		})
-	accountPresenter.New(sessionStore, q.ServiceSadia).Mount(app.Group("/account"))
+	impersonation := middleware.Impersonation(sessionStore, adminSessionStore)
+	resolveCompany := middleware.ResolveCompany(q.TenantResolver, q.TenantBaseDomain)
+	providerAvailable := middleware.ProviderAvailable(q.IdentityProvider)
+	refreshToken := middleware.RefreshToken(sessionStore, q.IdentityProvider, q.SadiaRefreshWindow)
+	accountPresenter.New(sessionStore, q.IdentityProvider, resolveCompany).Mount(app.Group("/account", providerAvailable, refreshToken, impersonation))
+	// the admin area manages users through Sadia's admin API, which has no local counterpart
+	if q.ServiceSadia != nil {
+		adminPresenter.New(sessionStore, adminSessionStore, q.ServiceSadia, auditQuery.New(q.DB), resolveCompany).Mount(app.Group("/admin", providerAvailable, impersonation))
+	} else {
+		helper.Log(ctx, zap.InfoLevel, "admin area disabled: it requires the sadia identity provider", ctxt, "")
+	}
+	v1 := app.Group("/v1", middleware.APIKey(apiKeyQuery.New(q.DB, q.DB)), providerAvailable)
+	accountPresenter.NewAPI(q.IdentityProvider).Mount(v1.Group("/account"))
 	app.Use(func(c *fiber.Ctx) error {
 		return helper.NewResponse(fiber.StatusNotFound).WriteResponse(c)
 	})
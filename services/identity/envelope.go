@@ -0,0 +1,35 @@
+package identity
+
+import (
+	"slices"
+	"time"
+)
+
+type (
+	// Envelope is the wrapper identity providers put around every response body.
+	Envelope[T any] struct {
+		RequestID  string            `json:"request_id"`
+		RequestURL string            `json:"request_url"`
+		StatusCode int               `json:"status_code"`
+		Status     string            `json:"status"`
+		Message    string            `json:"message"`
+		Timestamp  time.Time         `json:"timestamp"`
+		Latency    string            `json:"latency"`
+		App        string            `json:"app"`
+		Errors     map[string]string `json:"errors"`
+		Data       T                 `json:"data"`
+	}
+)
+
+// Expect returns nil if the response has one of statusCodes, otherwise the response as an *Error.
+func (q *Envelope[T]) Expect(statusCodes ...int) error {
+	if slices.Contains(statusCodes, q.StatusCode) {
+		return nil
+	}
+	return q.Err()
+}
+
+// Err returns the response as an *Error, classified by NewError.
+func (q *Envelope[T]) Err() *Error {
+	return NewError(q.StatusCode, q.RequestID, q.Message, q.Errors)
+}
@@ -0,0 +1,145 @@
+package identity
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	ErrUnavailable        = errors.New("identity provider unavailable")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrAccountLocked      = errors.New("account locked")
+	ErrAccountUnconfirmed = errors.New("account unconfirmed")
+	ErrAccountDeactivated = errors.New("account deactivated")
+	ErrRateLimited        = errors.New("rate limited")
+	ErrInvalidRequest     = errors.New("invalid request")
+	ErrForbidden          = errors.New("forbidden")
+	ErrNotFound           = errors.New("not found")
+	ErrNotSupported       = errors.New("not supported")
+
+	// formFields are the fields of the account forms FieldErrors reports messages for.
+	formFields = []string{"name", "username", "email", "phone", "password", "password_confirmation"}
+
+	userMessages = []struct {
+		err     error
+		message string
+	}{
+		{ErrInvalidCredentials, "The login or password you entered is incorrect."},
+		{ErrAccountLocked, "Your account is locked after too many failed attempts. Please try again later or reset your password."},
+		{ErrAccountUnconfirmed, "Please confirm your email or phone before logging in."},
+		{ErrAccountDeactivated, "Your account has been deactivated. Please contact support."},
+		{ErrRateLimited, "Too many attempts. Please wait a moment and try again."},
+		{ErrUnavailable, "The sign-in service is unavailable right now. Please try again in a moment."},
+		{ErrInvalidRequest, "Some of the details you entered are invalid. Please check them and try again."},
+		{ErrForbidden, "You don't have permission to do that."},
+		{ErrNotFound, "We couldn't find what you were looking for."},
+		{ErrNotSupported, "This feature isn't available right now."},
+	}
+)
+
+type (
+	// Error is a non-success response from an identity provider. It unwraps to one of the
+	// ErrXxx kinds when the response could be classified, so callers can branch with errors.Is.
+	Error struct {
+		StatusCode int
+		RequestID  string
+		// Message is the provider's own text, meant for logs rather than end users.
+		Message string
+		Errors  map[string]string
+		kind    error
+	}
+)
+
+// NewError classifies a non-success response by its status code, falling back to its
+// message for the statuses providers share between several account states.
+func NewError(statusCode int, requestID, message string, errs map[string]string) *Error {
+	response := Error{
+		StatusCode: statusCode,
+		RequestID:  requestID,
+		Message:    message,
+		Errors:     errs,
+	}
+	lowerCasedMessage := strings.ToLower(message)
+	switch {
+	case statusCode == fiber.StatusTooManyRequests:
+		response.kind = ErrRateLimited
+	case statusCode == fiber.StatusNotImplemented:
+		response.kind = ErrNotSupported
+	case statusCode >= fiber.StatusInternalServerError:
+		response.kind = ErrUnavailable
+	case statusCode == fiber.StatusLocked,
+		strings.Contains(lowerCasedMessage, "locked"):
+		response.kind = ErrAccountLocked
+	case strings.Contains(lowerCasedMessage, "deactivated"),
+		strings.Contains(lowerCasedMessage, "inactive"):
+		response.kind = ErrAccountDeactivated
+	case strings.Contains(lowerCasedMessage, "unconfirmed"),
+		strings.Contains(lowerCasedMessage, "not confirmed"):
+		response.kind = ErrAccountUnconfirmed
+	case statusCode == fiber.StatusUnauthorized:
+		response.kind = ErrInvalidCredentials
+	case statusCode == fiber.StatusForbidden:
+		response.kind = ErrForbidden
+	case statusCode == fiber.StatusNotFound:
+		response.kind = ErrNotFound
+	case statusCode == fiber.StatusBadRequest,
+		statusCode == fiber.StatusConflict,
+		statusCode == fiber.StatusUnprocessableEntity:
+		response.kind = ErrInvalidRequest
+	}
+	return &response
+}
+
+func (q *Error) Error() string {
+	var builder strings.Builder
+	_, _ = builder.WriteString("identity: ")
+	_, _ = builder.WriteString(strconv.Itoa(q.StatusCode))
+	if q.Message != "" {
+		_, _ = builder.WriteString(" ")
+		_, _ = builder.WriteString(q.Message)
+	}
+	return builder.String()
+}
+
+func (q *Error) Unwrap() error {
+	return q.kind
+}
+
+// UserMessage returns text fit for end users describing err, never the provider's own wording.
+func UserMessage(err error) string {
+	for _, userMessage := range userMessages {
+		if errors.Is(err, userMessage.err) {
+			return userMessage.message
+		}
+	}
+	return "Something went wrong. Please try again."
+}
+
+// FieldErrors returns the provider's messages for the form fields err rejected. Only the fields
+// of the account forms are kept, so messages about anything else never reach end users.
+func FieldErrors(err error) map[string]string {
+	var response *Error
+	if !errors.As(err, &response) || len(response.Errors) == 0 {
+		return nil
+	}
+	fieldErrors := make(map[string]string, len(response.Errors))
+	for field, message := range response.Errors {
+		if slices.Contains(formFields, field) && message != "" {
+			fieldErrors[field] = message
+		}
+	}
+	return fieldErrors
+}
+
+// StatusCode returns the status the provider answered err with, or 502 Bad Gateway when it never answered.
+func StatusCode(err error) int {
+	var response *Error
+	if errors.As(err, &response) && response.StatusCode != 0 {
+		return response.StatusCode
+	}
+	return fiber.StatusBadGateway
+}
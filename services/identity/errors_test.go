@@ -0,0 +1,94 @@
+package identity
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestNewError(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusCode  int
+		message     string
+		wantKind    error
+		wantMessage string
+	}{
+		{"401 is invalid credentials", fiber.StatusUnauthorized, "invalid login or password", ErrInvalidCredentials, "The login or password you entered is incorrect."},
+		{"423 is a locked account", fiber.StatusLocked, "", ErrAccountLocked, "Your account is locked after too many failed attempts. Please try again later or reset your password."},
+		{"403 saying locked is a locked account", fiber.StatusForbidden, "Account is locked", ErrAccountLocked, "Your account is locked after too many failed attempts. Please try again later or reset your password."},
+		{"401 saying unconfirmed is an unconfirmed account", fiber.StatusUnauthorized, "account not confirmed", ErrAccountUnconfirmed, "Please confirm your email or phone before logging in."},
+		{"403 saying deactivated is a deactivated account", fiber.StatusForbidden, "Account deactivated", ErrAccountDeactivated, "Your account has been deactivated. Please contact support."},
+		{"403 saying inactive is a deactivated account", fiber.StatusForbidden, "user is inactive", ErrAccountDeactivated, "Your account has been deactivated. Please contact support."},
+		{"429 is rate limited", fiber.StatusTooManyRequests, "", ErrRateLimited, "Too many attempts. Please wait a moment and try again."},
+		{"500 is unavailable", fiber.StatusInternalServerError, "", ErrUnavailable, "The sign-in service is unavailable right now. Please try again in a moment."},
+		{"503 is unavailable", fiber.StatusServiceUnavailable, "", ErrUnavailable, "The sign-in service is unavailable right now. Please try again in a moment."},
+		{"5xx mentioning a locked account is still unavailable", fiber.StatusBadGateway, "database locked", ErrUnavailable, "The sign-in service is unavailable right now. Please try again in a moment."},
+		{"400 is an invalid request", fiber.StatusBadRequest, "", ErrInvalidRequest, "Some of the details you entered are invalid. Please check them and try again."},
+		{"409 is an invalid request", fiber.StatusConflict, "", ErrInvalidRequest, "Some of the details you entered are invalid. Please check them and try again."},
+		{"422 is an invalid request", fiber.StatusUnprocessableEntity, "", ErrInvalidRequest, "Some of the details you entered are invalid. Please check them and try again."},
+		{"403 is forbidden", fiber.StatusForbidden, "", ErrForbidden, "You don't have permission to do that."},
+		{"404 is not found", fiber.StatusNotFound, "", ErrNotFound, "We couldn't find what you were looking for."},
+		{"501 is not supported", fiber.StatusNotImplemented, "", ErrNotSupported, "This feature isn't available right now."},
+		{"an unknown status falls back to a generic message", fiber.StatusTeapot, "", nil, "Something went wrong. Please try again."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewError(tt.statusCode, "request-1", tt.message, nil)
+			if kind := errors.Unwrap(err); kind != tt.wantKind {
+				t.Errorf("NewError() kind = %v, want %v", kind, tt.wantKind)
+			}
+			if message := UserMessage(err); message != tt.wantMessage {
+				t.Errorf("UserMessage() = %q, want %q", message, tt.wantMessage)
+			}
+			if statusCode := StatusCode(err); statusCode != tt.statusCode {
+				t.Errorf("StatusCode() = %d, want %d", statusCode, tt.statusCode)
+			}
+		})
+	}
+}
+
+func TestUserMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantMessage string
+	}{
+		{"a wrapped kind keeps its message", errors.Join(errors.New("dial tcp: connection refused"), ErrUnavailable), "The sign-in service is unavailable right now. Please try again in a moment."},
+		{"provider wording never leaks", NewError(fiber.StatusTeapot, "", "internal stack trace", nil), "Something went wrong. Please try again."},
+		{"an unclassified error gets the generic message", errors.New("boom"), "Something went wrong. Please try again."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if message := UserMessage(tt.err); message != tt.wantMessage {
+				t.Errorf("UserMessage() = %q, want %q", message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	err := NewError(fiber.StatusUnprocessableEntity, "", "validation failed", map[string]string{
+		"username":   "has already been taken",
+		"email":      "is invalid",
+		"company_id": "references a deleted company",
+		"phone":      "",
+	})
+	fieldErrors := FieldErrors(err)
+	want := map[string]string{
+		"username": "has already been taken",
+		"email":    "is invalid",
+	}
+	if len(fieldErrors) != len(want) {
+		t.Fatalf("FieldErrors() = %v, want %v", fieldErrors, want)
+	}
+	for field, message := range want {
+		if fieldErrors[field] != message {
+			t.Errorf("FieldErrors()[%q] = %q, want %q", field, fieldErrors[field], message)
+		}
+	}
+	if fieldErrors := FieldErrors(errors.New("boom")); fieldErrors != nil {
+		t.Errorf("FieldErrors() = %v, want nil", fieldErrors)
+	}
+}
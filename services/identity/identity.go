@@ -0,0 +1,136 @@
+package identity
+
+import (
+	"context"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ProviderSadia = "sadia"
+	ProviderLocal = "local"
+	// RetryAfter is how long callers are told to wait while a provider is unavailable.
+	RetryAfter = 30 * time.Second
+)
+
+type (
+	// TokenVerifier validates the JWTs an identity provider issues.
+	TokenVerifier interface {
+		Verify(ctx context.Context, token string) (*jwt.RegisteredClaims, error)
+	}
+
+	// IdentityProvider authenticates users and manages their profile, companies and sessions.
+	// Features not every provider has live in the extension interfaces below, which
+	// callers check for with a type assertion.
+	IdentityProvider interface {
+		TokenVerifier
+		Login(ctx context.Context, login, password string) (*ResponseUserLogin, error)
+		Register(ctx context.Context, request RegisterRequest) (*ResponseUserLogin, error)
+		Refresh(ctx context.Context, jwt string) (*ResponseUserLogin, error)
+		Logout(ctx context.Context, jwt string) error
+		Me(ctx context.Context, jwt string) (*ResponseUser, error)
+		UpdateProfile(ctx context.Context, jwt string, request UpdateProfileRequest) (*ResponseUser, error)
+		ChangePassword(ctx context.Context, jwt, currentPassword, newPassword string) (*ResponseMessage, error)
+		ListCompanies(ctx context.Context, jwt string) (*ResponseCompanies, error)
+		SwitchCompany(ctx context.Context, jwt, companyID string) (*ResponseUserLogin, error)
+		ListSessions(ctx context.Context, jwt string) (*ResponseSessions, error)
+		RevokeSession(ctx context.Context, jwt, sessionID string) (*ResponseMessage, error)
+		RevokeOtherSessions(ctx context.Context, jwt string) (*ResponseMessage, error)
+		// Available reports whether the provider can serve calls right now.
+		Available() bool
+	}
+
+	// PasswordResetter is implemented by providers that can send password reset links.
+	PasswordResetter interface {
+		ForgotPassword(ctx context.Context, login string) (*ResponseMessage, error)
+		ResetPassword(ctx context.Context, token, password string) (*ResponseMessage, error)
+	}
+
+	// ContactConfirmer is implemented by providers that can confirm emails and phones.
+	ContactConfirmer interface {
+		SendEmailConfirmation(ctx context.Context, jwt string) (*ResponseMessage, error)
+		SendPhoneConfirmation(ctx context.Context, jwt string) (*ResponseMessage, error)
+		ConfirmPhone(ctx context.Context, jwt, otp string) (*ResponseUser, error)
+	}
+
+	LoginRequest struct {
+		Login    string `json:"login"`
+		Password string `json:"password"`
+	}
+
+	RegisterRequest struct {
+		Name     string  `json:"name"`
+		Username string  `json:"username"`
+		Email    *string `json:"email"`
+		Phone    *string `json:"phone"`
+		Password string  `json:"password"`
+	}
+
+	UpdateProfileRequest struct {
+		Name     string  `json:"name"`
+		Username string  `json:"username"`
+		Email    *string `json:"email"`
+		Phone    *string `json:"phone"`
+	}
+
+	UserLoginResponse struct {
+		IDToken   string    `json:"id_token"`
+		ExpiredAt time.Time `json:"expired_at"`
+		User      User      `json:"user"`
+	}
+
+	User struct {
+		ID                      string     `json:"id"`
+		AccountType             uint8      `json:"account_type"`
+		Status                  int8       `json:"status"`
+		Name                    string     `json:"name"`
+		Username                string     `json:"username"`
+		ConfirmedAt             *time.Time `json:"confirmed_at"`
+		Email                   *string    `json:"email"`
+		UnconfirmedEmail        *string    `json:"unconfirmed_email"`
+		EmailConfirmationSentAt *time.Time `json:"email_confirmation_sent_at"`
+		EmailConfirmedAt        *time.Time `json:"email_confirmed_at"`
+		Phone                   *string    `json:"phone"`
+		UnconfirmedPhone        *string    `json:"unconfirmed_phone"`
+		PhoneConfirmationSentAt *time.Time `json:"phone_confirmation_sent_at"`
+		PhoneConfirmedAt        *time.Time `json:"phone_confirmed_at"`
+		LastPasswordChange      *time.Time `json:"last_password_change"`
+		ResetPasswordSentAt     *time.Time `json:"reset_password_sent_at"`
+		LoginCount              uint       `json:"login_count"`
+		CurrentLoginAt          *time.Time `json:"current_login_at"`
+		CurrentLoginIP          *string    `json:"current_login_ip"`
+		LastLoginAt             *time.Time `json:"last_login_at"`
+		LastLoginIP             *string    `json:"last_login_ip"`
+		LoginFailedAttempts     int        `json:"login_failed_attempts"`
+		LoginLockedAt           *time.Time `json:"login_locked_at"`
+		CreatedAt               time.Time  `json:"created_at"`
+		UpdatedAt               time.Time  `json:"updated_at"`
+		DeactivatedAt           *time.Time `json:"deactivated_at"`
+		CompanyID               string     `json:"company_id"`
+		UserLevel               uint8      `json:"user_level"`
+		CurrentSessionID        *string    `json:"current_session_id"`
+	}
+
+	Company struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+
+	Session struct {
+		ID         string    `json:"id"`
+		Device     string    `json:"device"`
+		UserAgent  string    `json:"user_agent"`
+		IP         string    `json:"ip"`
+		CreatedAt  time.Time `json:"created_at"`
+		LastSeenAt time.Time `json:"last_seen_at"`
+		ExpiredAt  time.Time `json:"expired_at"`
+	}
+
+	ResponseUserLogin = Envelope[UserLoginResponse]
+	ResponseUser      = Envelope[User]
+	ResponseSessions  = Envelope[[]Session]
+	ResponseCompanies = Envelope[[]Company]
+	ResponseMessage   = Envelope[json.RawMessage]
+)
@@ -0,0 +1,514 @@
+package local
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/models"
+	userModel "github.com/roysitumorang/bracha/modules/user/model"
+	userQuery "github.com/roysitumorang/bracha/modules/user/query"
+	"github.com/roysitumorang/bracha/services/identity"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	DefaultTokenTTL  = time.Hour
+	DefaultCompanyID = "default"
+	tokenIssuer      = "bracha"
+	tokenAudience    = "bracha"
+	loginLockAfter   = 5
+	loginLockTime    = 15 * time.Minute
+	app              = "bracha-local"
+)
+
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+)
+
+type (
+	// ServiceLocal is an identity provider backed by the users table, for running
+	// without Sadia. It covers logins, profiles and sessions but sends no email or SMS,
+	// so it resets no passwords and confirms no emails or phones.
+	ServiceLocal struct {
+		userQuery userQuery.UserQuery
+		secret    []byte
+		tokenTTL  time.Duration
+		companyID string
+		dummyHash []byte
+	}
+)
+
+func New(userQuery userQuery.UserQuery, secret []byte, tokenTTL time.Duration, companyID string) (*ServiceLocal, error) {
+	// compared against when the login matches no user, so unknown logins take as long as wrong passwords
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte(rand.Text()), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	return &ServiceLocal{
+		userQuery: userQuery,
+		secret:    secret,
+		tokenTTL:  tokenTTL,
+		companyID: companyID,
+		dummyHash: dummyHash,
+	}, nil
+}
+
+func (q *ServiceLocal) Login(ctx context.Context, login, password string) (*identity.ResponseUserLogin, error) {
+	ctxt := "ServiceLocal-Login"
+	requestID, _ := helper.RequestID(ctx)
+	user, err := q.userQuery.FindUserByLogin(ctx, login)
+	if errors.Is(err, userModel.ErrNotFound) {
+		_ = bcrypt.CompareHashAndPassword(q.dummyHash, helper.String2ByteSlice(password))
+		return nil, identity.NewError(fiber.StatusUnauthorized, requestID, "Invalid login or password", nil)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrFindUserByLogin")
+		return nil, unavailable(err)
+	}
+	// the account's state is only revealed to callers who know its password
+	if err = bcrypt.CompareHashAndPassword(helper.String2ByteSlice(user.PasswordHash), helper.String2ByteSlice(password)); err != nil {
+		if _, err = q.userQuery.RecordLoginFailure(ctx, user.ID, loginLockAfter); err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrRecordLoginFailure")
+			return nil, unavailable(err)
+		}
+		return nil, identity.NewError(fiber.StatusUnauthorized, requestID, "Invalid login or password", nil)
+	}
+	if user.DeactivatedAt != nil {
+		return nil, identity.NewError(fiber.StatusForbidden, requestID, "Account is deactivated", nil)
+	}
+	if user.LoginLockedAt != nil && time.Since(*user.LoginLockedAt) < loginLockTime {
+		return nil, identity.NewError(fiber.StatusLocked, requestID, "Account is locked", nil)
+	}
+	if err = q.userQuery.RecordLogin(ctx, user.ID); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrRecordLogin")
+		return nil, unavailable(err)
+	}
+	data, err := q.startSession(ctx, user)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrStartSession")
+		return nil, err
+	}
+	return envelope(ctx, fiber.StatusCreated, "", *data), nil
+}
+
+func (q *ServiceLocal) Register(ctx context.Context, request identity.RegisterRequest) (*identity.ResponseUserLogin, error) {
+	ctxt := "ServiceLocal-Register"
+	if response, err := q.validateLogins(ctx, "", request.Username, request.Email, request.Phone); err != nil || response != nil {
+		if err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrValidateLogins")
+			return nil, err
+		}
+		return envelopeWithErrors[identity.UserLoginResponse](ctx, response), nil
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword(helper.String2ByteSlice(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGenerateFromPassword")
+		return nil, err
+	}
+	user := userModel.User{
+		ID:           uuid.NewString(),
+		CompanyID:    q.companyID,
+		AccountType:  models.AccountTypeCustomer,
+		Name:         request.Name,
+		Username:     request.Username,
+		Email:        request.Email,
+		Phone:        request.Phone,
+		PasswordHash: helper.ByteSlice2String(passwordHash),
+	}
+	if err = q.userQuery.CreateUser(ctx, &user); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrCreateUser")
+		return nil, unavailable(err)
+	}
+	if err = q.userQuery.RecordLogin(ctx, user.ID); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrRecordLogin")
+		return nil, unavailable(err)
+	}
+	data, err := q.startSession(ctx, &user)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrStartSession")
+		return nil, err
+	}
+	return envelope(ctx, fiber.StatusCreated, "", *data), nil
+}
+
+func (q *ServiceLocal) Refresh(ctx context.Context, jwt string) (*identity.ResponseUserLogin, error) {
+	ctxt := "ServiceLocal-Refresh"
+	requestID, _ := helper.RequestID(ctx)
+	user, session, err := q.authenticate(ctx, jwt)
+	if errors.Is(err, ErrUnauthorized) {
+		return nil, identity.NewError(fiber.StatusUnauthorized, requestID, "Unauthorized", nil)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrAuthenticate")
+		return nil, err
+	}
+	expiredAt := time.Now().Add(q.tokenTTL)
+	if err = q.userQuery.ExtendSession(ctx, session.ID, expiredAt); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrExtendSession")
+		return nil, unavailable(err)
+	}
+	data, err := q.issueToken(user, session.ID, expiredAt)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrIssueToken")
+		return nil, err
+	}
+	return envelope(ctx, fiber.StatusCreated, "", *data), nil
+}
+
+func (q *ServiceLocal) Logout(ctx context.Context, jwt string) error {
+	ctxt := "ServiceLocal-Logout"
+	requestID, _ := helper.RequestID(ctx)
+	claims, err := q.Verify(ctx, jwt)
+	if errors.Is(err, ErrUnauthorized) {
+		return identity.NewError(fiber.StatusUnauthorized, requestID, "Unauthorized", nil)
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrVerify")
+		return err
+	}
+	if err = q.userQuery.RevokeSession(ctx, claims.Subject, claims.ID); err != nil && !errors.Is(err, userModel.ErrNotFound) {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrRevokeSession")
+		return unavailable(err)
+	}
+	return nil
+}
+
+func (q *ServiceLocal) Me(ctx context.Context, jwt string) (*identity.ResponseUser, error) {
+	ctxt := "ServiceLocal-Me"
+	user, session, err := q.authenticate(ctx, jwt)
+	if errors.Is(err, ErrUnauthorized) {
+		return envelope(ctx, fiber.StatusUnauthorized, "Unauthorized", identity.User{}), nil
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrAuthenticate")
+		return nil, err
+	}
+	return envelope(ctx, fiber.StatusOK, "", toUser(user, session.ID)), nil
+}
+
+func (q *ServiceLocal) UpdateProfile(ctx context.Context, jwt string, request identity.UpdateProfileRequest) (*identity.ResponseUser, error) {
+	ctxt := "ServiceLocal-UpdateProfile"
+	user, session, err := q.authenticate(ctx, jwt)
+	if errors.Is(err, ErrUnauthorized) {
+		return envelope(ctx, fiber.StatusUnauthorized, "Unauthorized", identity.User{}), nil
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrAuthenticate")
+		return nil, err
+	}
+	if response, err := q.validateLogins(ctx, user.ID, request.Username, request.Email, request.Phone); err != nil || response != nil {
+		if err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrValidateLogins")
+			return nil, err
+		}
+		return envelopeWithErrors[identity.User](ctx, response), nil
+	}
+	user.Name = request.Name
+	user.Username = request.Username
+	user.Email = request.Email
+	user.Phone = request.Phone
+	if err = q.userQuery.UpdateProfile(ctx, user); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrUpdateProfile")
+		return nil, unavailable(err)
+	}
+	return envelope(ctx, fiber.StatusOK, "Profile updated", toUser(user, session.ID)), nil
+}
+
+// ChangePassword also revokes the user's other sessions, as Sadia does.
+func (q *ServiceLocal) ChangePassword(ctx context.Context, jwt, currentPassword, newPassword string) (*identity.ResponseMessage, error) {
+	ctxt := "ServiceLocal-ChangePassword"
+	user, session, err := q.authenticate(ctx, jwt)
+	if errors.Is(err, ErrUnauthorized) {
+		return message(ctx, fiber.StatusUnauthorized, "Unauthorized"), nil
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrAuthenticate")
+		return nil, err
+	}
+	if err = bcrypt.CompareHashAndPassword(helper.String2ByteSlice(user.PasswordHash), helper.String2ByteSlice(currentPassword)); err != nil {
+		return message(ctx, fiber.StatusBadRequest, "Current password is incorrect"), nil
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword(helper.String2ByteSlice(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrGenerateFromPassword")
+		return nil, err
+	}
+	if err = q.userQuery.UpdatePassword(ctx, user.ID, helper.ByteSlice2String(passwordHash)); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrUpdatePassword")
+		return nil, unavailable(err)
+	}
+	if err = q.userQuery.RevokeOtherSessions(ctx, user.ID, session.ID); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrRevokeOtherSessions")
+		return nil, unavailable(err)
+	}
+	return message(ctx, fiber.StatusOK, "Password changed"), nil
+}
+
+// ListCompanies returns the user's only company; local users belong to exactly one.
+func (q *ServiceLocal) ListCompanies(ctx context.Context, jwt string) (*identity.ResponseCompanies, error) {
+	ctxt := "ServiceLocal-ListCompanies"
+	user, _, err := q.authenticate(ctx, jwt)
+	if errors.Is(err, ErrUnauthorized) {
+		return envelope[[]identity.Company](ctx, fiber.StatusUnauthorized, "Unauthorized", nil), nil
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrAuthenticate")
+		return nil, err
+	}
+	return envelope(ctx, fiber.StatusOK, "", []identity.Company{
+		{
+			ID:   user.CompanyID,
+			Name: user.CompanyID,
+		},
+	}), nil
+}
+
+func (q *ServiceLocal) SwitchCompany(ctx context.Context, _, _ string) (*identity.ResponseUserLogin, error) {
+	return envelope(ctx, fiber.StatusForbidden, "Local users belong to a single company", identity.UserLoginResponse{}), nil
+}
+
+func (q *ServiceLocal) ListSessions(ctx context.Context, jwt string) (*identity.ResponseSessions, error) {
+	ctxt := "ServiceLocal-ListSessions"
+	user, _, err := q.authenticate(ctx, jwt)
+	if errors.Is(err, ErrUnauthorized) {
+		return envelope[[]identity.Session](ctx, fiber.StatusUnauthorized, "Unauthorized", nil), nil
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrAuthenticate")
+		return nil, err
+	}
+	sessions, err := q.userQuery.FindActiveSessions(ctx, user.ID)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrFindActiveSessions")
+		return nil, unavailable(err)
+	}
+	data := make([]identity.Session, len(sessions))
+	for i, session := range sessions {
+		data[i] = identity.Session{
+			ID:         session.ID,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiredAt:  session.ExpiredAt,
+		}
+	}
+	return envelope(ctx, fiber.StatusOK, "", data), nil
+}
+
+func (q *ServiceLocal) RevokeSession(ctx context.Context, jwt, sessionID string) (*identity.ResponseMessage, error) {
+	ctxt := "ServiceLocal-RevokeSession"
+	user, _, err := q.authenticate(ctx, jwt)
+	if errors.Is(err, ErrUnauthorized) {
+		return message(ctx, fiber.StatusUnauthorized, "Unauthorized"), nil
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrAuthenticate")
+		return nil, err
+	}
+	if err = q.userQuery.RevokeSession(ctx, user.ID, sessionID); err != nil {
+		if errors.Is(err, userModel.ErrNotFound) {
+			return message(ctx, fiber.StatusNotFound, "Session not found"), nil
+		}
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrRevokeSession")
+		return nil, unavailable(err)
+	}
+	return message(ctx, fiber.StatusOK, "Session revoked"), nil
+}
+
+func (q *ServiceLocal) RevokeOtherSessions(ctx context.Context, jwt string) (*identity.ResponseMessage, error) {
+	ctxt := "ServiceLocal-RevokeOtherSessions"
+	user, session, err := q.authenticate(ctx, jwt)
+	if errors.Is(err, ErrUnauthorized) {
+		return message(ctx, fiber.StatusUnauthorized, "Unauthorized"), nil
+	}
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrAuthenticate")
+		return nil, err
+	}
+	if err = q.userQuery.RevokeOtherSessions(ctx, user.ID, session.ID); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrRevokeOtherSessions")
+		return nil, unavailable(err)
+	}
+	return message(ctx, fiber.StatusOK, "Other sessions revoked"), nil
+}
+
+func (q *ServiceLocal) Available() bool {
+	return true
+}
+
+// Verify checks the token's signature, expiry, issuer and audience, and that the session it
+// was issued for hasn't been revoked or expired since.
+func (q *ServiceLocal) Verify(ctx context.Context, token string) (*jwt.RegisteredClaims, error) {
+	claims, _, err := q.activeSession(ctx, token)
+	return claims, err
+}
+
+// activeSession resolves a token to its claims and still active session, failing with
+// ErrUnauthorized when either is invalid, revoked or expired.
+func (q *ServiceLocal) activeSession(ctx context.Context, token string) (*jwt.RegisteredClaims, *userModel.Session, error) {
+	var claims jwt.RegisteredClaims
+	if _, err := jwt.ParseWithClaims(
+		token,
+		&claims,
+		func(_ *jwt.Token) (any, error) {
+			return q.secret, nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithAudience(tokenAudience),
+		jwt.WithExpirationRequired(),
+	); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+	session, err := q.userQuery.FindActiveSession(ctx, claims.ID)
+	if errors.Is(err, userModel.ErrNotFound) || err == nil && session.UserID != claims.Subject {
+		return nil, nil, ErrUnauthorized
+	}
+	if err != nil {
+		return nil, nil, unavailable(err)
+	}
+	return &claims, session, nil
+}
+
+// authenticate resolves a token to its user and still active session, failing with
+// ErrUnauthorized when any of them is invalid, revoked or deactivated.
+func (q *ServiceLocal) authenticate(ctx context.Context, jwt string) (*userModel.User, *userModel.Session, error) {
+	_, session, err := q.activeSession(ctx, jwt)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := q.userQuery.FindUserByID(ctx, session.UserID)
+	if errors.Is(err, userModel.ErrNotFound) || err == nil && user.DeactivatedAt != nil {
+		return nil, nil, ErrUnauthorized
+	}
+	if err != nil {
+		return nil, nil, unavailable(err)
+	}
+	return user, session, nil
+}
+
+func (q *ServiceLocal) startSession(ctx context.Context, user *userModel.User) (*identity.UserLoginResponse, error) {
+	session := userModel.Session{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		ExpiredAt: time.Now().Add(q.tokenTTL),
+	}
+	if err := q.userQuery.CreateSession(ctx, &session); err != nil {
+		return nil, unavailable(err)
+	}
+	return q.issueToken(user, session.ID, session.ExpiredAt)
+}
+
+func (q *ServiceLocal) issueToken(user *userModel.User, sessionID string, expiredAt time.Time) (*identity.UserLoginResponse, error) {
+	now := time.Now()
+	idToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    tokenIssuer,
+		Subject:   user.ID,
+		Audience:  jwt.ClaimStrings{tokenAudience},
+		ExpiresAt: jwt.NewNumericDate(expiredAt),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ID:        sessionID,
+	}).SignedString(q.secret)
+	if err != nil {
+		return nil, err
+	}
+	return &identity.UserLoginResponse{
+		IDToken:   idToken,
+		ExpiredAt: expiredAt,
+		User:      toUser(user, sessionID),
+	}, nil
+}
+
+// validateLogins returns the field errors for a username that could pass for an email or
+// phone, for a malformed phone, or for a username, email or phone another user already has,
+// or nil when all are fine.
+func (q *ServiceLocal) validateLogins(ctx context.Context, exceptUserID, username string, email, phone *string) (map[string]string, error) {
+	if !userModel.UsernameRegex.MatchString(username) {
+		return map[string]string{"username": "Username must be 3-32 letters, digits, dots or underscores"}, nil
+	}
+	if userModel.PhoneRegex.MatchString(username) {
+		return map[string]string{"username": "Username can't look like a phone number"}, nil
+	}
+	if phone != nil && *phone != "" && !userModel.PhoneRegex.MatchString(*phone) {
+		return map[string]string{"phone": "Phone is invalid"}, nil
+	}
+	fields, err := q.userQuery.FindTakenFields(ctx, exceptUserID, username, email, phone)
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	fieldErrors := make(map[string]string, len(fields))
+	for _, field := range fields {
+		fieldErrors[field] = utils.ToUpper(field[:1]) + field[1:] + " is already taken"
+	}
+	return fieldErrors, nil
+}
+
+func toUser(user *userModel.User, sessionID string) identity.User {
+	return identity.User{
+		ID:                  user.ID,
+		AccountType:         user.AccountType,
+		Name:                user.Name,
+		Username:            user.Username,
+		Email:               user.Email,
+		Phone:               user.Phone,
+		LastPasswordChange:  user.LastPasswordChange,
+		LoginCount:          user.LoginCount,
+		CurrentLoginAt:      user.CurrentLoginAt,
+		LastLoginAt:         user.LastLoginAt,
+		LoginFailedAttempts: user.LoginFailedAttempts,
+		LoginLockedAt:       user.LoginLockedAt,
+		CreatedAt:           user.CreatedAt,
+		UpdatedAt:           user.UpdatedAt,
+		DeactivatedAt:       user.DeactivatedAt,
+		CompanyID:           user.CompanyID,
+		UserLevel:           user.UserLevel,
+		CurrentSessionID:    &sessionID,
+	}
+}
+
+func envelope[T any](ctx context.Context, statusCode int, message string, data T) *identity.Envelope[T] {
+	requestID, _ := helper.RequestID(ctx)
+	return &identity.Envelope[T]{
+		RequestID:  requestID,
+		StatusCode: statusCode,
+		Status:     utils.StatusMessage(statusCode),
+		Message:    message,
+		Timestamp:  time.Now(),
+		App:        app,
+		Data:       data,
+	}
+}
+
+func envelopeWithErrors[T any](ctx context.Context, fieldErrors map[string]string) *identity.Envelope[T] {
+	messages := make([]string, 0, len(fieldErrors))
+	for _, fieldError := range fieldErrors {
+		messages = append(messages, fieldError)
+	}
+	var data T
+	response := envelope(ctx, fiber.StatusBadRequest, strings.Join(messages, ", "), data)
+	response.Errors = fieldErrors
+	return response
+}
+
+func message(ctx context.Context, statusCode int, message string) *identity.ResponseMessage {
+	return envelope[json.RawMessage](ctx, statusCode, message, nil)
+}
+
+// unavailable marks err, raised by the users table, as the provider being unavailable,
+// so a database outage is handled like any other provider outage.
+func unavailable(err error) error {
+	return fmt.Errorf("%w: %w", identity.ErrUnavailable, err)
+}
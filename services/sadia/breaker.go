@@ -0,0 +1,130 @@
+package sadia
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+type (
+	CircuitState uint8
+
+	// CircuitBreaker stops calls to Sadia after threshold consecutive failures, then lets a
+	// single probe through once cooldown has passed; the probe's outcome closes or reopens it.
+	CircuitBreaker struct {
+		mu        sync.Mutex
+		state     CircuitState
+		failures  int
+		probing   bool
+		openedAt  time.Time
+		threshold int
+		cooldown  time.Duration
+	}
+
+	CircuitBreakerStats struct {
+		State    string     `json:"state"`
+		Failures int        `json:"failures"`
+		OpenedAt *time.Time `json:"opened_at"`
+	}
+)
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+func (q CircuitState) String() string {
+	switch q {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Allow reports whether a call may go out. Every allowed call must be followed by Success,
+// Failure or Release.
+func (q *CircuitBreaker) Allow() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	switch q.currentState() {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		if q.probing {
+			return false
+		}
+		q.state = CircuitHalfOpen
+		q.probing = true
+	}
+	return true
+}
+
+func (q *CircuitBreaker) Success() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.state = CircuitClosed
+	q.failures = 0
+	q.probing = false
+}
+
+func (q *CircuitBreaker) Failure() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.failures++
+	if q.state == CircuitHalfOpen || q.failures >= q.threshold {
+		q.state = CircuitOpen
+		q.openedAt = time.Now()
+	}
+	q.probing = false
+}
+
+// Release ends an allowed call that never got an answer from Sadia, such as one cancelled
+// by its caller, without counting it either way.
+func (q *CircuitBreaker) Release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.probing = false
+}
+
+// State returns the breaker's state, reporting an open breaker whose cooldown has passed as half-open.
+func (q *CircuitBreaker) State() CircuitState {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.currentState()
+}
+
+func (q *CircuitBreaker) Stats() CircuitBreakerStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	response := CircuitBreakerStats{
+		State:    q.currentState().String(),
+		Failures: q.failures,
+	}
+	if q.state != CircuitClosed {
+		openedAt := q.openedAt
+		response.OpenedAt = &openedAt
+	}
+	return response
+}
+
+func (q *CircuitBreaker) currentState() CircuitState {
+	if q.state == CircuitOpen && time.Since(q.openedAt) >= q.cooldown {
+		return CircuitHalfOpen
+	}
+	return q.state
+}
@@ -0,0 +1,134 @@
+package sadia
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	const threshold = 3
+	// trip opens the breaker and backdates it past its cooldown, leaving it half-open
+	trip := func(breaker *CircuitBreaker) {
+		for range threshold {
+			breaker.Allow()
+			breaker.Failure()
+		}
+		breaker.openedAt = time.Now().Add(-DefaultBreakerCooldown)
+	}
+	tests := []struct {
+		name         string
+		run          func(*CircuitBreaker) []bool
+		wantAllowed  []bool
+		wantState    CircuitState
+		wantFailures int
+	}{
+		{
+			name: "failures below the threshold keep it closed",
+			run: func(breaker *CircuitBreaker) []bool {
+				for range threshold - 1 {
+					breaker.Allow()
+					breaker.Failure()
+				}
+				return []bool{breaker.Allow()}
+			},
+			wantAllowed:  []bool{true},
+			wantState:    CircuitClosed,
+			wantFailures: threshold - 1,
+		},
+		{
+			name: "a success resets the failure count",
+			run: func(breaker *CircuitBreaker) []bool {
+				for range threshold - 1 {
+					breaker.Allow()
+					breaker.Failure()
+				}
+				breaker.Allow()
+				breaker.Success()
+				breaker.Allow()
+				breaker.Failure()
+				return nil
+			},
+			wantState:    CircuitClosed,
+			wantFailures: 1,
+		},
+		{
+			name: "reaching the threshold opens it",
+			run: func(breaker *CircuitBreaker) []bool {
+				for range threshold {
+					breaker.Allow()
+					breaker.Failure()
+				}
+				return []bool{breaker.Allow()}
+			},
+			wantAllowed:  []bool{false},
+			wantState:    CircuitOpen,
+			wantFailures: threshold,
+		},
+		{
+			name: "half-open lets a single probe through",
+			run: func(breaker *CircuitBreaker) []bool {
+				trip(breaker)
+				return []bool{breaker.Allow(), breaker.Allow()}
+			},
+			wantAllowed:  []bool{true, false},
+			wantState:    CircuitHalfOpen,
+			wantFailures: threshold,
+		},
+		{
+			name: "a succeeding probe closes it",
+			run: func(breaker *CircuitBreaker) []bool {
+				trip(breaker)
+				allowed := breaker.Allow()
+				breaker.Success()
+				return []bool{allowed, breaker.Allow()}
+			},
+			wantAllowed:  []bool{true, true},
+			wantState:    CircuitClosed,
+			wantFailures: 0,
+		},
+		{
+			name: "a failing probe reopens it",
+			run: func(breaker *CircuitBreaker) []bool {
+				trip(breaker)
+				allowed := breaker.Allow()
+				breaker.Failure()
+				return []bool{allowed, breaker.Allow()}
+			},
+			wantAllowed:  []bool{true, false},
+			wantState:    CircuitOpen,
+			wantFailures: threshold + 1,
+		},
+		{
+			name: "a released probe lets the next one through",
+			run: func(breaker *CircuitBreaker) []bool {
+				trip(breaker)
+				allowed := breaker.Allow()
+				breaker.Release()
+				return []bool{allowed, breaker.Allow()}
+			},
+			wantAllowed:  []bool{true, true},
+			wantState:    CircuitHalfOpen,
+			wantFailures: threshold,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := NewCircuitBreaker(threshold, DefaultBreakerCooldown)
+			allowed := tt.run(breaker)
+			if len(allowed) != len(tt.wantAllowed) {
+				t.Fatalf("Allow() results = %v, want %v", allowed, tt.wantAllowed)
+			}
+			for i := range allowed {
+				if allowed[i] != tt.wantAllowed[i] {
+					t.Fatalf("Allow() results = %v, want %v", allowed, tt.wantAllowed)
+				}
+			}
+			if state := breaker.State(); state != tt.wantState {
+				t.Errorf("State() = %s, want %s", state, tt.wantState)
+			}
+			if failures := breaker.Stats().Failures; failures != tt.wantFailures {
+				t.Errorf("failures = %d, want %d", failures, tt.wantFailures)
+			}
+		})
+	}
+}
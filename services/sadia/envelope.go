@@ -0,0 +1,38 @@
+package sadia
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/services/identity"
+	"go.uber.org/zap"
+)
+
+// call sends payload, if any, as the JSON body of a request to endpoint and decodes Sadia's
+// envelope around a T. Bodiless responses such as 204 yield an envelope carrying only the status code.
+func call[T any](ctx context.Context, q *ServiceSadia, requestMethod, endpoint string, urlValues url.Values, jwt string, payload any) (*identity.Envelope[T], error) {
+	ctxt := "ServiceSadia-call"
+	_, statusCode, respBody, err := q.hitEndpoint(ctx, endpoint, requestMethod, urlValues, jwt, payload)
+	if err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrHitEndpoint")
+		return nil, err
+	}
+	var response identity.Envelope[T]
+	if len(respBody) > 0 {
+		if err = json.Unmarshal(respBody, &response); err != nil {
+			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrUnmarshal")
+			// gateways in front of Sadia answer outages and throttling with bodies of their own
+			if statusCode >= fiber.StatusInternalServerError || statusCode == fiber.StatusTooManyRequests {
+				return nil, identity.NewError(statusCode, "", "", nil)
+			}
+			return nil, err
+		}
+	}
+	if response.StatusCode == 0 {
+		response.StatusCode = statusCode
+	}
+	return &response, nil
+}
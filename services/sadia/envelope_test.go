@@ -0,0 +1,58 @@
+package sadia
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/services/identity"
+)
+
+func TestCall(t *testing.T) {
+	helper.InitLogger()
+	tests := []struct {
+		name        string
+		statusCode  int
+		contentType string
+		body        string
+		wantKind    error
+		wantMessage string
+	}{
+		{"an envelope is classified by its status", fiber.StatusUnauthorized, fiber.MIMEApplicationJSON, `{"status_code":401,"message":"invalid login or password"}`, identity.ErrInvalidCredentials, "The login or password you entered is incorrect."},
+		{"an undecodable 502 is unavailable", fiber.StatusBadGateway, fiber.MIMETextHTML, "<html>Bad Gateway</html>", identity.ErrUnavailable, "The sign-in service is unavailable right now. Please try again in a moment."},
+		{"an undecodable 500 is unavailable", fiber.StatusInternalServerError, fiber.MIMETextPlain, "Internal Server Error", identity.ErrUnavailable, "The sign-in service is unavailable right now. Please try again in a moment."},
+		{"an undecodable 429 is rate limited", fiber.StatusTooManyRequests, fiber.MIMETextHTML, "<html>Too Many Requests</html>", identity.ErrRateLimited, "Too many attempts. Please wait a moment and try again."},
+		{"an undecodable 400 stays unclassified", fiber.StatusBadRequest, fiber.MIMETextHTML, "<html>Bad Request</html>", nil, "Something went wrong. Please try again."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set(fiber.HeaderContentType, tt.contentType)
+				w.WriteHeader(tt.statusCode)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			baseURL, _ := url.Parse(server.URL)
+			// POST isn't retried, so every case takes a single attempt
+			service := New(baseURL, DefaultTimeout, DefaultMaxRetries)
+			response, err := call[User](context.Background(), service, fiber.MethodPost, "/account/login", nil, "", nil)
+			if err == nil {
+				err = response.Expect(fiber.StatusOK)
+			}
+			if err == nil {
+				t.Fatal("call() error = nil, want an error")
+			}
+			if tt.wantKind != nil && !errors.Is(err, tt.wantKind) {
+				t.Errorf("call() error = %v, want %v", err, tt.wantKind)
+			}
+			if message := identity.UserMessage(err); message != tt.wantMessage {
+				t.Errorf("UserMessage() = %q, want %q", message, tt.wantMessage)
+			}
+		})
+	}
+}
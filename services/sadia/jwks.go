@@ -0,0 +1,282 @@
+package sadia
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/services/identity"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultJWKSPath            = "/.well-known/jwks.json"
+	DefaultJWKSRefreshInterval = time.Hour
+	// jwksMinRefetchInterval keeps tokens signed with unknown kids from hammering Sadia.
+	jwksMinRefetchInterval = time.Minute
+	jwksFetchTimeout       = 10 * time.Second
+	jwtLeeway              = 30 * time.Second
+)
+
+var (
+	ErrUnknownKey = errors.New("jwt signed with unknown key")
+
+	jwtValidMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+)
+
+type (
+	// Verifier validates Sadia JWTs against the signing keys published in Sadia's JWKS,
+	// refetching the key set in the background and whenever a token names an unknown kid.
+	Verifier struct {
+		jwksURL         *url.URL
+		issuer          string
+		audience        string
+		refreshInterval time.Duration
+
+		mu   sync.RWMutex
+		keys map[string]crypto.PublicKey
+		// attemptedAt and attemptErr describe the last fetch, failed ones included,
+		// so an unreachable endpoint is retried at most once per jwksMinRefetchInterval
+		attemptedAt time.Time
+		attemptErr  error
+		fetching    sync.Mutex
+	}
+
+	JWKS struct {
+		Keys []JWK `json:"keys"`
+	}
+
+	JWK struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Use string `json:"use"`
+		Alg string `json:"alg"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+)
+
+func NewVerifier(jwksURL *url.URL, issuer, audience string, refreshInterval time.Duration) *Verifier {
+	return &Verifier{
+		jwksURL:         jwksURL,
+		issuer:          issuer,
+		audience:        audience,
+		refreshInterval: refreshInterval,
+		keys:            map[string]crypto.PublicKey{},
+	}
+}
+
+// Run refreshes the key set every refresh interval until ctx is done.
+func (q *Verifier) Run(ctx context.Context) error {
+	ctxt := "SadiaVerifier-Run"
+	if err := q.Refresh(ctx); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrRefresh")
+	}
+	ticker := time.NewTicker(q.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := q.Refresh(ctx); err != nil {
+				helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrRefresh")
+			}
+		}
+	}
+}
+
+// Refresh fetches the key set and replaces the cached keys. Keys missing from the new set are
+// dropped, so a key Sadia rotates out stops verifying tokens after the next refresh.
+func (q *Verifier) Refresh(ctx context.Context) error {
+	q.fetching.Lock()
+	defer q.fetching.Unlock()
+	return q.refresh(ctx)
+}
+
+// refresh is Refresh for callers already holding fetching. Failures wrap identity.ErrUnavailable.
+func (q *Verifier) refresh(ctx context.Context) error {
+	jwks, err := q.fetch(ctx)
+	if err == nil {
+		err = q.SetKeys(jwks)
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: fetch jwks: %w", identity.ErrUnavailable, err)
+		q.mu.Lock()
+		q.attemptedAt = time.Now()
+		q.attemptErr = err
+		q.mu.Unlock()
+	}
+	return err
+}
+
+func (q *Verifier) fetch(ctx context.Context) (jwks JWKS, err error) {
+	ctxt := "SadiaVerifier-fetch"
+	request := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(request)
+	response := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(response)
+	request.SetRequestURI(q.jwksURL.String())
+	request.Header.SetMethod(fiber.MethodGet)
+	if err = fasthttp.DoTimeout(request, response, jwksFetchTimeout); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrDoTimeout")
+		return
+	}
+	if statusCode := response.StatusCode(); statusCode != fiber.StatusOK {
+		err = fmt.Errorf("unexpected status %d", statusCode)
+		return
+	}
+	if err = json.Unmarshal(response.Body(), &jwks); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrUnmarshal")
+	}
+	return
+}
+
+// SetKeys replaces the cached keys with the signing keys of jwks.
+func (q *Verifier) SetKeys(jwks JWKS) error {
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			return fmt.Errorf("jwk %s: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return errors.New("jwks has no signing keys")
+	}
+	q.mu.Lock()
+	q.keys = keys
+	q.attemptedAt = time.Now()
+	q.attemptErr = nil
+	q.mu.Unlock()
+	return nil
+}
+
+// Verify checks the token's signature, expiry, issuer and audience and returns its claims.
+func (q *Verifier) Verify(ctx context.Context, token string) (*jwt.RegisteredClaims, error) {
+	var claims jwt.RegisteredClaims
+	if _, err := jwt.ParseWithClaims(
+		token,
+		&claims,
+		func(token *jwt.Token) (any, error) {
+			kid, _ := token.Header["kid"].(string)
+			return q.key(ctx, kid)
+		},
+		jwt.WithValidMethods(jwtValidMethods),
+		jwt.WithIssuer(q.issuer),
+		jwt.WithAudience(q.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(jwtLeeway),
+	); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+func (q *Verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	ctxt := "SadiaVerifier-key"
+	if key, ok, err := q.cachedKey(kid); ok || err != nil {
+		return key, err
+	}
+	q.fetching.Lock()
+	defer q.fetching.Unlock()
+	// another request may have refetched the key set while this one waited for the lock
+	if key, ok, err := q.cachedKey(kid); ok || err != nil {
+		return key, err
+	}
+	if err := q.refresh(ctx); err != nil {
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrRefresh")
+		return nil, err
+	}
+	q.mu.RLock()
+	key, ok := q.keys[kid]
+	q.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// cachedKey returns the cached key for kid. When there is none and the key set was fetched
+// too recently to try again, it returns the outcome of that fetch: its error, or ErrUnknownKey.
+func (q *Verifier) cachedKey(kid string) (crypto.PublicKey, bool, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if key, ok := q.keys[kid]; ok {
+		return key, true, nil
+	}
+	if time.Since(q.attemptedAt) >= jwksMinRefetchInterval {
+		return nil, false, nil
+	}
+	if q.attemptErr != nil {
+		return nil, false, q.attemptErr
+	}
+	return nil, false, ErrUnknownKey
+}
+
+// PublicKey decodes an RSA or EC JWK.
+func (q JWK) PublicKey() (crypto.PublicKey, error) {
+	switch q.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(q.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(q.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch q.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", q.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(q.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(q.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", q.Kty)
+	}
+}
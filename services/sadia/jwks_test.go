@@ -0,0 +1,212 @@
+package sadia
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/services/identity"
+)
+
+const (
+	testIssuer   = "https://sadia.test"
+	testAudience = "bracha"
+)
+
+type (
+	testKey struct {
+		kid     string
+		method  jwt.SigningMethod
+		private crypto.Signer
+		jwk     JWK
+	}
+)
+
+func newRSATestKey(t *testing.T, kid string) testKey {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{
+		kid:     kid,
+		method:  jwt.SigningMethodRS256,
+		private: private,
+		jwk: JWK{
+			Kid: kid,
+			Kty: "RSA",
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(private.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(private.E)).Bytes()),
+		},
+	}
+}
+
+func newECTestKey(t *testing.T, kid string) testKey {
+	t.Helper()
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, y := make([]byte, 32), make([]byte, 32)
+	private.X.FillBytes(x)
+	private.Y.FillBytes(y)
+	return testKey{
+		kid:     kid,
+		method:  jwt.SigningMethodES256,
+		private: private,
+		jwk: JWK{
+			Kid: kid,
+			Kty: "EC",
+			Use: "sig",
+			Alg: "ES256",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(x),
+			Y:   base64.RawURLEncoding.EncodeToString(y),
+		},
+	}
+}
+
+func validClaims() jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		Issuer:    testIssuer,
+		Subject:   "user-1",
+		Audience:  jwt.ClaimStrings{testAudience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func newTestVerifier(t *testing.T, keys ...testKey) *Verifier {
+	t.Helper()
+	jwksURL, _ := url.Parse("http://sadia.invalid" + DefaultJWKSPath)
+	verifier := NewVerifier(jwksURL, testIssuer, testAudience, DefaultJWKSRefreshInterval)
+	var jwks JWKS
+	for _, key := range keys {
+		jwks.Keys = append(jwks.Keys, key.jwk)
+	}
+	if err := verifier.SetKeys(jwks); err != nil {
+		t.Fatal(err)
+	}
+	return verifier
+}
+
+func TestVerifierVerify(t *testing.T) {
+	ctx := context.Background()
+	rsaKey := newRSATestKey(t, "rsa-1")
+	ecKey := newECTestKey(t, "ec-1")
+	otherRSAKey := newRSATestKey(t, "rsa-1")
+	verifier := newTestVerifier(t, rsaKey, ecKey)
+
+	wrongIssuer := validClaims()
+	wrongIssuer.Issuer = "https://evil.test"
+	wrongAudience := validClaims()
+	wrongAudience.Audience = jwt.ClaimStrings{"someone-else"}
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	withoutExpiry := validClaims()
+	withoutExpiry.ExpiresAt = nil
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"valid RSA token", sign(t, rsaKey.method, rsaKey.kid, rsaKey.private, validClaims()), nil},
+		{"valid EC token", sign(t, ecKey.method, ecKey.kid, ecKey.private, validClaims()), nil},
+		{"bad signature", sign(t, otherRSAKey.method, rsaKey.kid, otherRSAKey.private, validClaims()), jwt.ErrTokenSignatureInvalid},
+		{"wrong issuer", sign(t, rsaKey.method, rsaKey.kid, rsaKey.private, wrongIssuer), jwt.ErrTokenInvalidIssuer},
+		{"wrong audience", sign(t, rsaKey.method, rsaKey.kid, rsaKey.private, wrongAudience), jwt.ErrTokenInvalidAudience},
+		{"expired", sign(t, rsaKey.method, rsaKey.kid, rsaKey.private, expired), jwt.ErrTokenExpired},
+		{"missing exp", sign(t, rsaKey.method, rsaKey.kid, rsaKey.private, withoutExpiry), jwt.ErrTokenRequiredClaimMissing},
+		{"alg not allowed", sign(t, jwt.SigningMethodHS256, rsaKey.kid, []byte("shared-secret"), validClaims()), jwt.ErrTokenSignatureInvalid},
+		{"unknown kid", sign(t, rsaKey.method, "rsa-unknown", rsaKey.private, validClaims()), ErrUnknownKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(ctx, tt.token)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Verify() error = %v, want nil", err)
+				}
+				if claims.Subject != "user-1" {
+					t.Errorf("Subject = %q, want %q", claims.Subject, "user-1")
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifierRotation(t *testing.T) {
+	ctx := context.Background()
+	oldKey := newRSATestKey(t, "rsa-old")
+	newKey := newRSATestKey(t, "rsa-new")
+	verifier := newTestVerifier(t, oldKey)
+	oldToken := sign(t, oldKey.method, oldKey.kid, oldKey.private, validClaims())
+	if _, err := verifier.Verify(ctx, oldToken); err != nil {
+		t.Fatalf("Verify() before rotation error = %v, want nil", err)
+	}
+	if err := verifier.SetKeys(JWKS{Keys: []JWK{newKey.jwk}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.Verify(ctx, oldToken); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Verify() with rotated out kid error = %v, want %v", err, ErrUnknownKey)
+	}
+	if _, err := verifier.Verify(ctx, sign(t, newKey.method, newKey.kid, newKey.private, validClaims())); err != nil {
+		t.Fatalf("Verify() with new kid error = %v, want nil", err)
+	}
+}
+
+func TestVerifierUnavailable(t *testing.T) {
+	helper.InitLogger()
+	ctx := context.Background()
+	var fetches atomic.Int32
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer jwks.Close()
+	jwksURL, _ := url.Parse(jwks.URL + DefaultJWKSPath)
+	verifier := NewVerifier(jwksURL, testIssuer, testAudience, DefaultJWKSRefreshInterval)
+	key := newRSATestKey(t, "rsa-1")
+	token := sign(t, key.method, key.kid, key.private, validClaims())
+	for range 3 {
+		if _, err := verifier.Verify(ctx, token); !errors.Is(err, identity.ErrUnavailable) {
+			t.Fatalf("Verify() error = %v, want %v", err, identity.ErrUnavailable)
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("JWKS fetches = %d, want 1 within jwksMinRefetchInterval", got)
+	}
+}
@@ -0,0 +1,28 @@
+package sadia
+
+import (
+	"context"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type (
+	// Provider is the identity.IdentityProvider backed by Sadia, which also resets
+	// passwords and confirms emails and phones.
+	Provider struct {
+		*ServiceSadia
+		verifier *Verifier
+	}
+)
+
+// NewProvider returns the identity provider backed by serviceSadia, whose JWTs are checked by verifier.
+func NewProvider(serviceSadia *ServiceSadia, verifier *Verifier) *Provider {
+	return &Provider{
+		ServiceSadia: serviceSadia,
+		verifier:     verifier,
+	}
+}
+
+func (q *Provider) Verify(ctx context.Context, token string) (*jwt.RegisteredClaims, error) {
+	return q.verifier.Verify(ctx, token)
+}
@@ -3,7 +3,11 @@ package sadia
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -12,94 +16,306 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/services/identity"
 	"github.com/valyala/fasthttp"
 	"go.uber.org/zap"
 )
 
+const (
+	DefaultTimeout             = 10 * time.Second
+	DefaultMaxRetries          = 2
+	DefaultMaxConns            = 512
+	DefaultMaxIdleConnDuration = time.Minute
+	retryBaseBackoff           = 100 * time.Millisecond
+	retryMaxBackoff            = 2 * time.Second
+)
+
+var (
+	idempotentMethods    = []string{fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, fiber.MethodPut, fiber.MethodDelete}
+	retryableStatusCodes = []int{fiber.StatusBadGateway, fiber.StatusServiceUnavailable, fiber.StatusGatewayTimeout}
+)
+
 type (
 	ServiceSadia struct {
-		baseURL *url.URL
+		baseURL    *url.URL
+		client     *fasthttp.HostClient
+		breaker    *CircuitBreaker
+		timeout    time.Duration
+		maxRetries int
 	}
 
-	LoginRequest struct {
-		Login    string `json:"login"`
+	ForgotPasswordRequest struct {
+		Login string `json:"login"`
+	}
+
+	ResetPasswordRequest struct {
+		Token    string `json:"token"`
 		Password string `json:"password"`
 	}
 
-	UserLoginResponse struct {
-		IDToken   string    `json:"id_token"`
-		ExpiredAt time.Time `json:"expired_at"`
-		User      User      `json:"user"`
-	}
-
-	User struct {
-		ID                      string     `json:"id"`
-		AccountType             uint8      `json:"account_type"`
-		Status                  int8       `json:"status"`
-		Name                    string     `json:"name"`
-		Username                string     `json:"username"`
-		ConfirmedAt             *time.Time `json:"confirmed_at"`
-		Email                   *string    `json:"email"`
-		UnconfirmedEmail        *string    `json:"unconfirmed_email"`
-		EmailConfirmationSentAt *time.Time `json:"email_confirmation_sent_at"`
-		EmailConfirmedAt        *time.Time `json:"email_confirmed_at"`
-		Phone                   *string    `json:"phone"`
-		UnconfirmedPhone        *string    `json:"unconfirmed_phone"`
-		PhoneConfirmationSentAt *time.Time `json:"phone_confirmation_sent_at"`
-		PhoneConfirmedAt        *time.Time `json:"phone_confirmed_at"`
-		LastPasswordChange      *time.Time `json:"last_password_change"`
-		ResetPasswordSentAt     *time.Time `json:"reset_password_sent_at"`
-		LoginCount              uint       `json:"login_count"`
-		CurrentLoginAt          *time.Time `json:"current_login_at"`
-		CurrentLoginIP          *string    `json:"current_login_ip"`
-		LastLoginAt             *time.Time `json:"last_login_at"`
-		LastLoginIP             *string    `json:"last_login_ip"`
-		LoginFailedAttempts     int        `json:"login_failed_attempts"`
-		LoginLockedAt           *time.Time `json:"login_locked_at"`
-		CreatedAt               time.Time  `json:"created_at"`
-		UpdatedAt               time.Time  `json:"updated_at"`
-		DeactivatedAt           *time.Time `json:"deactivated_at"`
-		CompanyID               string     `json:"company_id"`
-		UserLevel               uint8      `json:"user_level"`
-		CurrentSessionID        *string    `json:"current_session_id"`
-	}
-
-	ResponseUserLogin struct {
-		RequestID  string            `json:"request_id"`
-		RequestURL string            `json:"request_url"`
-		StatusCode int               `json:"status_code"`
-		Status     string            `json:"status"`
-		Message    string            `json:"message"`
-		Timestamp  time.Time         `json:"timestamp"`
-		Latency    string            `json:"latency"`
-		App        string            `json:"app"`
-		Data       UserLoginResponse `json:"data"`
+	ChangePasswordRequest struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+
+	ConfirmPhoneRequest struct {
+		OTP string `json:"otp"`
+	}
+
+	SwitchCompanyRequest struct {
+		CompanyID string `json:"company_id"`
+	}
+
+	UserFilter struct {
+		Name      string
+		Username  string
+		Email     string
+		Phone     string
+		CompanyID string
+		Status    string
+		Sort      string
+		Page      int
+		Size      int
+	}
+
+	Pagination struct {
+		Page       int `json:"page"`
+		Size       int `json:"size"`
+		Total      int `json:"total"`
+		TotalPages int `json:"total_pages"`
+	}
+
+	UserList struct {
+		Users      []User     `json:"users"`
+		Pagination Pagination `json:"pagination"`
 	}
+
+	// the types Sadia shares with every identity provider
+	LoginRequest         = identity.LoginRequest
+	RegisterRequest      = identity.RegisterRequest
+	UpdateProfileRequest = identity.UpdateProfileRequest
+	UserLoginResponse    = identity.UserLoginResponse
+	User                 = identity.User
+	Company              = identity.Company
+	Session              = identity.Session
+
+	ResponseUserLogin = identity.ResponseUserLogin
+	ResponseUser      = identity.ResponseUser
+	ResponseSessions  = identity.ResponseSessions
+	ResponseUserList  = identity.Envelope[UserList]
+	ResponseCompanies = identity.ResponseCompanies
+	ResponseMessage   = identity.ResponseMessage
 )
 
-func New(baseURL *url.URL) *ServiceSadia {
+func New(baseURL *url.URL, timeout time.Duration, maxRetries int) *ServiceSadia {
+	isTLS := baseURL.Scheme == "https"
 	return &ServiceSadia{
 		baseURL: baseURL,
+		client: &fasthttp.HostClient{
+			Addr:                fasthttp.AddMissingPort(baseURL.Host, isTLS),
+			IsTLS:               isTLS,
+			MaxConns:            DefaultMaxConns,
+			MaxIdleConnDuration: DefaultMaxIdleConnDuration,
+			ReadTimeout:         timeout,
+			WriteTimeout:        timeout,
+		},
+		breaker:    NewCircuitBreaker(DefaultBreakerThreshold, DefaultBreakerCooldown),
+		timeout:    timeout,
+		maxRetries: maxRetries,
 	}
 }
 
+// CircuitBreaker reports the state of the breaker guarding calls to Sadia.
+func (q *ServiceSadia) CircuitBreaker() CircuitBreakerStats {
+	return q.breaker.Stats()
+}
+
+// Available reports whether calls to Sadia may go out, i.e. the breaker isn't open.
+func (q *ServiceSadia) Available() bool {
+	return q.breaker.State() != CircuitOpen
+}
+
 func (q *ServiceSadia) Login(ctx context.Context, login, password string) (*ResponseUserLogin, error) {
-	ctxt := "ServiceSadia-Login"
 	request := LoginRequest{
 		Login:    login,
 		Password: helper.Base64Encode(password),
 	}
-	_, _, respBody, err := q.hitEndpoint(ctx, "/account/login", fiber.MethodPost, nil, "", request)
+	response, err := call[UserLoginResponse](ctx, q, fiber.MethodPost, "/account/login", nil, "", request)
 	if err != nil {
-		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrHitEndpoint")
 		return nil, err
 	}
-	var response ResponseUserLogin
-	if err = json.Unmarshal(respBody, &response); err != nil {
-		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrUnmarshal")
+	if err = response.Expect(fiber.StatusCreated); err != nil {
 		return nil, err
 	}
-	return &response, nil
+	return response, nil
+}
+
+func (q *ServiceSadia) Register(ctx context.Context, request RegisterRequest) (*ResponseUserLogin, error) {
+	request.Password = helper.Base64Encode(request.Password)
+	return call[UserLoginResponse](ctx, q, fiber.MethodPost, "/account/register", nil, "", request)
+}
+
+func (q *ServiceSadia) Refresh(ctx context.Context, jwt string) (*ResponseUserLogin, error) {
+	response, err := call[UserLoginResponse](ctx, q, fiber.MethodPost, "/account/refresh", nil, jwt, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = response.Expect(fiber.StatusCreated); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (q *ServiceSadia) Logout(ctx context.Context, jwt string) error {
+	response, err := call[json.RawMessage](ctx, q, fiber.MethodPost, "/account/logout", nil, jwt, nil)
+	if err != nil {
+		return err
+	}
+	return response.Expect(fiber.StatusOK, fiber.StatusNoContent)
+}
+
+func (q *ServiceSadia) ForgotPassword(ctx context.Context, login string) (*ResponseMessage, error) {
+	request := ForgotPasswordRequest{
+		Login: login,
+	}
+	return call[json.RawMessage](ctx, q, fiber.MethodPost, "/account/password/forgot", nil, "", request)
+}
+
+func (q *ServiceSadia) ResetPassword(ctx context.Context, token, password string) (*ResponseMessage, error) {
+	request := ResetPasswordRequest{
+		Token:    token,
+		Password: helper.Base64Encode(password),
+	}
+	return call[json.RawMessage](ctx, q, fiber.MethodPost, "/account/password/reset", nil, "", request)
+}
+
+func (q *ServiceSadia) UpdateProfile(ctx context.Context, jwt string, request UpdateProfileRequest) (*ResponseUser, error) {
+	return call[User](ctx, q, fiber.MethodPost, "/account/me/profile", nil, jwt, request)
+}
+
+func (q *ServiceSadia) ChangePassword(ctx context.Context, jwt, currentPassword, newPassword string) (*ResponseMessage, error) {
+	request := ChangePasswordRequest{
+		CurrentPassword: helper.Base64Encode(currentPassword),
+		NewPassword:     helper.Base64Encode(newPassword),
+	}
+	return call[json.RawMessage](ctx, q, fiber.MethodPost, "/account/me/password", nil, jwt, request)
+}
+
+func (q *ServiceSadia) Me(ctx context.Context, jwt string) (*ResponseUser, error) {
+	return call[User](ctx, q, fiber.MethodGet, "/account/me", nil, jwt, nil)
+}
+
+func (q *ServiceSadia) SendEmailConfirmation(ctx context.Context, jwt string) (*ResponseMessage, error) {
+	return call[json.RawMessage](ctx, q, fiber.MethodPost, "/account/me/confirmation/email", nil, jwt, nil)
+}
+
+func (q *ServiceSadia) SendPhoneConfirmation(ctx context.Context, jwt string) (*ResponseMessage, error) {
+	return call[json.RawMessage](ctx, q, fiber.MethodPost, "/account/me/confirmation/phone", nil, jwt, nil)
+}
+
+func (q *ServiceSadia) ConfirmPhone(ctx context.Context, jwt, otp string) (*ResponseUser, error) {
+	request := ConfirmPhoneRequest{
+		OTP: otp,
+	}
+	return call[User](ctx, q, fiber.MethodPost, "/account/me/confirmation/phone/verify", nil, jwt, request)
+}
+
+func (q *ServiceSadia) ListCompanies(ctx context.Context, jwt string) (*ResponseCompanies, error) {
+	return call[[]Company](ctx, q, fiber.MethodGet, "/account/me/companies", nil, jwt, nil)
+}
+
+func (q *ServiceSadia) SwitchCompany(ctx context.Context, jwt, companyID string) (*ResponseUserLogin, error) {
+	request := SwitchCompanyRequest{
+		CompanyID: companyID,
+	}
+	return call[UserLoginResponse](ctx, q, fiber.MethodPost, "/account/me/companies/switch", nil, jwt, request)
+}
+
+func (q *ServiceSadia) ListSessions(ctx context.Context, jwt string) (*ResponseSessions, error) {
+	return call[[]Session](ctx, q, fiber.MethodGet, "/account/me/sessions", nil, jwt, nil)
+}
+
+func (q *ServiceSadia) RevokeSession(ctx context.Context, jwt, sessionID string) (*ResponseMessage, error) {
+	return call[json.RawMessage](ctx, q, fiber.MethodDelete, "/account/me/sessions/"+url.PathEscape(sessionID), nil, jwt, nil)
+}
+
+func (q *ServiceSadia) RevokeOtherSessions(ctx context.Context, jwt string) (*ResponseMessage, error) {
+	return call[json.RawMessage](ctx, q, fiber.MethodPost, "/account/me/sessions/revoke-others", nil, jwt, nil)
+}
+
+func (q *ServiceSadia) AdminLogin(ctx context.Context, login, password string) (*ResponseUserLogin, error) {
+	request := LoginRequest{
+		Login:    login,
+		Password: helper.Base64Encode(password),
+	}
+	response, err := call[UserLoginResponse](ctx, q, fiber.MethodPost, "/admin/login", nil, "", request)
+	if err != nil {
+		return nil, err
+	}
+	if err = response.Expect(fiber.StatusCreated); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (q *ServiceSadia) AdminLogout(ctx context.Context, jwt string) error {
+	response, err := call[json.RawMessage](ctx, q, fiber.MethodPost, "/admin/logout", nil, jwt, nil)
+	if err != nil {
+		return err
+	}
+	return response.Expect(fiber.StatusOK, fiber.StatusNoContent)
+}
+
+func (q *ServiceSadia) ListUsers(ctx context.Context, jwt string, filter UserFilter) (*ResponseUserList, error) {
+	urlValues := url.Values{}
+	for key, value := range map[string]string{
+		"name":       filter.Name,
+		"username":   filter.Username,
+		"email":      filter.Email,
+		"phone":      filter.Phone,
+		"company_id": filter.CompanyID,
+		"status":     filter.Status,
+		"sort":       filter.Sort,
+	} {
+		if value != "" {
+			urlValues.Set(key, value)
+		}
+	}
+	if filter.Page > 0 {
+		urlValues.Set("page", strconv.Itoa(filter.Page))
+	}
+	if filter.Size > 0 {
+		urlValues.Set("size", strconv.Itoa(filter.Size))
+	}
+	return call[UserList](ctx, q, fiber.MethodGet, "/admin/users", urlValues, jwt, nil)
+}
+
+func (q *ServiceSadia) GetUser(ctx context.Context, jwt, userID string) (*ResponseUser, error) {
+	return call[User](ctx, q, fiber.MethodGet, "/admin/users/"+url.PathEscape(userID), nil, jwt, nil)
+}
+
+func (q *ServiceSadia) UnlockUser(ctx context.Context, jwt, userID string) (*ResponseUser, error) {
+	return q.adminUserAction(ctx, jwt, userID, "unlock")
+}
+
+func (q *ServiceSadia) DeactivateUser(ctx context.Context, jwt, userID string) (*ResponseUser, error) {
+	return q.adminUserAction(ctx, jwt, userID, "deactivate")
+}
+
+func (q *ServiceSadia) ReactivateUser(ctx context.Context, jwt, userID string) (*ResponseUser, error) {
+	return q.adminUserAction(ctx, jwt, userID, "reactivate")
+}
+
+func (q *ServiceSadia) ForceResetPassword(ctx context.Context, jwt, userID string) (*ResponseUser, error) {
+	return q.adminUserAction(ctx, jwt, userID, "reset-password")
+}
+
+func (q *ServiceSadia) ImpersonateUser(ctx context.Context, jwt, userID string) (*ResponseUserLogin, error) {
+	return call[UserLoginResponse](ctx, q, fiber.MethodPost, "/admin/users/"+url.PathEscape(userID)+"/impersonate", nil, jwt, nil)
+}
+
+func (q *ServiceSadia) adminUserAction(ctx context.Context, jwt, userID, action string) (*ResponseUser, error) {
+	return call[User](ctx, q, fiber.MethodPost, "/admin/users/"+url.PathEscape(userID)+"/"+action, nil, jwt, nil)
 }
 
 func (q *ServiceSadia) hitEndpoint(ctx context.Context, endpoint, requestMethod string, urlValues url.Values, jwt string, payload ...any) (requestURL string, statusCode int, responseBody []byte, err error) {
@@ -108,10 +324,15 @@ func (q *ServiceSadia) hitEndpoint(ctx context.Context, endpoint, requestMethod
 	_, _ = builder.WriteString(q.baseURL.String())
 	_, _ = builder.WriteString(endpoint)
 	request := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(request)
 	request.SetRequestURI(builder.String())
 	request.Header.SetMethod(requestMethod)
 	request.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
-	request.Header.Set(fiber.HeaderXRequestID, uuid.New().String())
+	requestID, ok := helper.RequestID(ctx)
+	if !ok {
+		requestID = uuid.New().String()
+	}
+	request.Header.Set(fiber.HeaderXRequestID, requestID)
 	if queryString := urlValues.Encode(); queryString != "" {
 		request.URI().SetQueryString(queryString)
 		_, _ = builder.WriteString("?")
@@ -124,8 +345,7 @@ func (q *ServiceSadia) hitEndpoint(ctx context.Context, endpoint, requestMethod
 		_, _ = builder.WriteString(jwt)
 		request.Header.Set(fiber.HeaderAuthorization, builder.String())
 	}
-	if requestMethod == fiber.MethodPost &&
-		len(payload) > 0 && payload[0] != nil {
+	if len(payload) > 0 && payload[0] != nil {
 		requestBody, err := json.Marshal(payload[0])
 		if err != nil {
 			helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrMarshal")
@@ -134,16 +354,62 @@ func (q *ServiceSadia) hitEndpoint(ctx context.Context, endpoint, requestMethod
 		request.SetBodyRaw(requestBody)
 	}
 	response := fasthttp.AcquireResponse()
-	if err = fasthttp.Do(request, response); err != nil {
+	defer fasthttp.ReleaseResponse(response)
+	idempotent := slices.Contains(idempotentMethods, requestMethod)
+	if !q.breaker.Allow() {
+		return requestURL, 0, nil, identity.ErrUnavailable
+	}
+	// the breaker counts logical calls, so only the last attempt's outcome is recorded
+	outcome := q.breaker.Release
+	defer func() {
+		outcome()
+	}()
+	for attempt := 0; ; attempt++ {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		deadline := time.Now().Add(q.timeout)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+		response.Reset()
+		err = q.client.DoDeadline(request, response, deadline)
+		statusCode = response.StatusCode()
+		if err != nil || statusCode >= fiber.StatusInternalServerError {
+			outcome = q.breaker.Failure
+		} else {
+			outcome = q.breaker.Success
+		}
+		retryable := err != nil && (idempotent || isDialError(err)) ||
+			err == nil && idempotent && slices.Contains(retryableStatusCodes, statusCode)
+		if !retryable || attempt >= q.maxRetries {
+			break
+		}
+		backoff := retryBackoff(attempt)
+		builder.Reset()
+		_, _ = builder.WriteString("retrying ")
+		_, _ = builder.WriteString(requestMethod)
+		_, _ = builder.WriteString(" ")
+		_, _ = builder.WriteString(requestURL)
+		_, _ = builder.WriteString(" in ")
+		_, _ = builder.WriteString(backoff.String())
+		helper.Log(ctx, zap.WarnLevel, builder.String(), ctxt, "")
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return requestURL, 0, nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if err != nil {
 		for errors.Unwrap(err) != nil {
 			err = errors.Unwrap(err)
 		}
-		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrDo")
-		return
+		helper.Log(ctx, zap.ErrorLevel, err.Error(), ctxt, "ErrDoDeadline")
+		return requestURL, 0, nil, fmt.Errorf("%w: %w", identity.ErrUnavailable, err)
 	}
-	fasthttp.ReleaseRequest(request)
-	statusCode = response.StatusCode()
-	responseBody = response.Body()
+	responseBody = slices.Clone(response.Body())
 	builder.Reset()
 	_, _ = builder.WriteString(requestMethod)
 	_, _ = builder.WriteString(" ")
@@ -153,3 +419,19 @@ func (q *ServiceSadia) hitEndpoint(ctx context.Context, endpoint, requestMethod
 	helper.Log(ctx, zap.InfoLevel, builder.String(), ctxt, "")
 	return
 }
+
+// retryBackoff returns a full-jitter exponential backoff for the given zero-based attempt.
+func retryBackoff(attempt int) time.Duration {
+	backoff := min(retryBaseBackoff<<attempt, retryMaxBackoff)
+	return time.Duration(rand.Int64N(int64(backoff))) + time.Millisecond
+}
+
+// isDialError reports whether err happened before the request reached Sadia, which makes
+// retrying safe even for non-idempotent methods.
+func isDialError(err error) bool {
+	if errors.Is(err, fasthttp.ErrDialTimeout) || errors.Is(err, fasthttp.ErrNoFreeConns) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,168 @@
+package sadia
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/roysitumorang/bracha/helper"
+	"github.com/roysitumorang/bracha/services/identity"
+)
+
+func TestHitEndpoint(t *testing.T) {
+	helper.InitLogger()
+	// every case starts with one failure on record, so a success shows as 0, a failure
+	// as 2 and a call recording nothing as 1, whatever number of attempts it took
+	const recordedFailures = 1
+	tests := []struct {
+		name string
+		// statuses answers each attempt in turn, repeating the last one
+		statuses []int
+		method   string
+		// drop closes the connection without answering
+		drop bool
+		// dialErr fails every dial before the request reaches the server
+		dialErr      bool
+		cancelled    bool
+		open         bool
+		wantAttempts int32
+		wantStatus   int
+		wantErr      error
+		wantFailures int
+	}{
+		{
+			name:         "GET is retried after a 503 and succeeds",
+			statuses:     []int{fiber.StatusServiceUnavailable, fiber.StatusOK},
+			method:       fiber.MethodGet,
+			wantAttempts: 2,
+			wantStatus:   fiber.StatusOK,
+			wantFailures: 0,
+		},
+		{
+			name:         "GET gives up after the max retries",
+			statuses:     []int{fiber.StatusServiceUnavailable},
+			method:       fiber.MethodGet,
+			wantAttempts: 3,
+			wantStatus:   fiber.StatusServiceUnavailable,
+			wantFailures: recordedFailures + 1,
+		},
+		{
+			name:         "GET isn't retried after a 500",
+			statuses:     []int{fiber.StatusInternalServerError, fiber.StatusOK},
+			method:       fiber.MethodGet,
+			wantAttempts: 1,
+			wantStatus:   fiber.StatusInternalServerError,
+			wantFailures: recordedFailures + 1,
+		},
+		{
+			name:         "GET isn't retried after a 4xx",
+			statuses:     []int{fiber.StatusNotFound, fiber.StatusOK},
+			method:       fiber.MethodGet,
+			wantAttempts: 1,
+			wantStatus:   fiber.StatusNotFound,
+			wantFailures: 0,
+		},
+		{
+			name:         "POST isn't retried after a 503",
+			statuses:     []int{fiber.StatusServiceUnavailable, fiber.StatusOK},
+			method:       fiber.MethodPost,
+			wantAttempts: 1,
+			wantStatus:   fiber.StatusServiceUnavailable,
+			wantFailures: recordedFailures + 1,
+		},
+		{
+			name:         "POST isn't retried once the request reached the server",
+			method:       fiber.MethodPost,
+			drop:         true,
+			wantAttempts: 1,
+			wantErr:      identity.ErrUnavailable,
+			wantFailures: recordedFailures + 1,
+		},
+		{
+			name:         "POST is retried on dial errors",
+			method:       fiber.MethodPost,
+			dialErr:      true,
+			wantAttempts: 3,
+			wantErr:      identity.ErrUnavailable,
+			wantFailures: recordedFailures + 1,
+		},
+		{
+			name:         "a cancelled call records nothing",
+			statuses:     []int{fiber.StatusOK},
+			method:       fiber.MethodGet,
+			cancelled:    true,
+			wantAttempts: 0,
+			wantErr:      context.Canceled,
+			wantFailures: recordedFailures,
+		},
+		{
+			name:         "an open breaker stops the call",
+			statuses:     []int{fiber.StatusOK},
+			method:       fiber.MethodGet,
+			open:         true,
+			wantAttempts: 0,
+			wantErr:      identity.ErrUnavailable,
+			wantFailures: DefaultBreakerThreshold,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt := int(attempts.Add(1))
+				_, _ = io.Copy(io.Discard, r.Body)
+				if tt.drop {
+					conn, _, err := w.(http.Hijacker).Hijack()
+					if err == nil {
+						_ = conn.Close()
+					}
+					return
+				}
+				w.Header().Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+				w.WriteHeader(tt.statuses[min(attempt, len(tt.statuses))-1])
+				_, _ = w.Write([]byte(`{}`))
+			}))
+			defer server.Close()
+			baseURL, _ := url.Parse(server.URL)
+			service := New(baseURL, time.Second, DefaultMaxRetries)
+			if tt.dialErr {
+				service.client.Dial = func(addr string) (net.Conn, error) {
+					attempts.Add(1)
+					return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+				}
+			}
+			service.breaker.failures = recordedFailures
+			if tt.open {
+				for range DefaultBreakerThreshold - recordedFailures {
+					service.breaker.Failure()
+				}
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+			_, statusCode, _, err := service.hitEndpoint(ctx, "/account/me", tt.method, nil, "", nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("hitEndpoint() error = %v, want %v", err, tt.wantErr)
+			}
+			if statusCode != tt.wantStatus {
+				t.Errorf("status code = %d, want %d", statusCode, tt.wantStatus)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+			if failures := service.breaker.Stats().Failures; failures != tt.wantFailures {
+				t.Errorf("failures = %d, want %d", failures, tt.wantFailures)
+			}
+		})
+	}
+}